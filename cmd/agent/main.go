@@ -1,81 +1,21 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/mtrrun/internal/agent"
 	"github.com/mtrrun/internal/config"
-)
-
-// Golang runtime metrics:
-// Metric name: "Alloc", type: gauge
-// Metric name: "BuckHashSys", type: gauge
-// Metric name: "Frees", type: gauge
-// Metric name: "GCCPUFraction", type: gauge
-// Metric name: "GCSys", type: gauge
-// Metric name: "HeapAlloc", type: gauge
-// Metric name: "HeapIdle", type: gauge
-// Metric name: "HeapInuse", type: gauge
-// Metric name: "HeapObjects", type: gauge
-// Metric name: "HeapReleased", type: gauge
-// Metric name: "HeapSys", type: gauge
-// Metric name: "LastGC", type: gauge
-// Metric name: "Lookups", type: gauge
-// Metric name: "MCacheInuse", type: gauge
-// Metric name: "MCacheSys", type: gauge
-// Metric name: "MSpanInuse", type: gauge
-// Metric name: "MSpanSys", type: gauge
-// Metric name: "Mallocs", type: gauge
-// Metric name: "NextGC", type: gauge
-// Metric name: "NumForcedGC", type: gauge
-// Metric name: "NumGC", type: gauge
-// Metric name: "OtherSys", type: gauge
-// Metric name: "PauseTotalNs", type: gauge
-// Metric name: "StackInuse", type: gauge
-// Metric name: "StackSys", type: gauge
-// Metric name: "Sys", type: gauge
-// Metric name: "TotalAlloc", type: gauge
-
-// Other custom metrics:
-// Metric name: "PollCount", type: counter — counter, incremented by 1 each time a metric from the runtime package is updated.
-// Metric name: "RandomValue", type: gauge — random value.
-
-const (
-	MetricAlloc       = "Alloc"
-	MetricBuckHashSys = "BuckHashSys"
-	MetricFrees       = "Frees"
-	MetricGCCPUFracti = "GCCPUFracti"
-	MetricGCSys       = "GCSys"
-	MetricHeapAlloc   = "HeapAlloc"
-	MetricHeapIdle    = "HeapIdle"
-	MetricHeapInuse   = "HeapInuse"
-	MetricHeapObjects = "HeapObjects"
-	MetricHeapRelease = "HeapRelease"
-	MetricHeapSys     = "HeapSys"
-	MetricLastGC      = "LastGC"
-	MetricLookups     = "Lookups"
-	MetricMCacheInuse = "MCacheSys"
-	MetricMCacheSys   = "CacheSys"
-	MetricMSpanInuse  = "MSpanInuse"
-	MetricMSpanSys    = "MSpanSys"
-	MetricMallocs     = "Mallocs"
-	MetricNextGC      = "NextGC"
-	MetricNumForcedGC = "NumForcedGC"
-	MetricNumGC       = "NumGC"
-	MetricOtherSys    = "OtherSys"
-	MetricPauseTotalN = "PauseTotalN"
-	MetricStackInuse  = "StackInuse"
-	MetricStackSys    = "StackSys"
-	MetricSys         = "Sys"
-	MetricTotalAlloc  = "TotalAlloc"
-	MetricPollCount   = "PollCount"
-	MetricRandomValue = "RandomValue"
+	"github.com/mtrrun/internal/relabel"
+	"github.com/mtrrun/pkg/metrics"
 )
 
 // For configuration
@@ -109,161 +49,353 @@ func main() {
 		PollInterval:         defaultPollInterval,
 	}
 
-	a, err := agent.New(&agent.Config{
+	flag.IntVar(&c.MaxRequestsPerMoment, "l", defaultMaxRequestsPerMoment, "count of workers which send requests to server")
+	flag.IntVar(&c.QueueSize, "queue-size", 100, "size of queue with requests for workers")
+	flag.Float64Var(&c.RequestsPerSecond, "rps", 0, "limit of requests per second to server. Rate is not limited if it is empty")
+	flag.IntVar(&c.Burst, "burst", 1, "count of requests which could be sent at once when rate is limited")
+	flag.StringVar(&c.Key, "k", "", "key for HMAC-SHA256 signing of metrics. Signing is disabled if key is empty")
+	flag.StringVar(&c.CryptoKey, "crypto-key", "", "path to PEM file with public key of server for encryption of metrics")
+	flag.StringVar(&c.TLSCA, "tls-ca", "", "path to PEM file with CA certificates for verifying server")
+	flag.StringVar(&c.TLSCert, "tls-cert", "", "path to PEM file with client certificate for mutual TLS")
+	flag.StringVar(&c.TLSKey, "tls-key", "", "path to PEM file with client key for mutual TLS")
+	flag.StringVar(&c.TLSServerName, "tls-server-name", "", "server name in certificate if it differs from host")
+	flag.StringVar(&c.Token, "token", "", "bearer token for server API")
+	flag.StringVar(&c.TokenFile, "token-file", "", "path to file with bearer token. File is re-read when it changes")
+	flag.IntVar(&c.Retries, "retries", 3, "count of retries for failed requests. Negative value disables retries")
+	flag.DurationVar(&c.RetryInitialInterval, "retry-initial-interval", 100*time.Millisecond, "initial interval of exponential backoff")
+	flag.DurationVar(&c.RetryMaxInterval, "retry-max-interval", 5*time.Second, "max interval of exponential backoff")
+	flag.StringVar(&c.SpoolDir, "spool-dir", "", "directory for reports which were not delivered. Spool is disabled if empty")
+	flag.Int64Var(&c.SpoolMaxSize, "spool-max-size", 64<<20, "max size of spool in bytes")
+	flag.DurationVar(&c.SpoolMaxAge, "spool-max-age", 24*time.Hour, "max age of reports in spool")
+	flag.StringVar(&c.ProcRoot, "proc-root", "/proc", "root of proc file system, e.g. /host/proc in container")
+	flag.StringVar(&c.SysRoot, "sys-root", "/sys", "root of sys file system, e.g. /host/sys in container")
+	hostFilesystems := flag.String("host-fs", "/", "comma separated list of mount points for usage of file systems")
+	collectors := flag.String("collectors", defaultCollectors(), "comma separated list of enabled collectors: runtime, host")
+	collectorIntervals := flag.String("collector-intervals", "", "comma separated list of poll intervals of collectors, e.g. host=10s,runtime=2s")
+	flag.BoolVar(&c.RuntimeCompat, "runtime-compat", true, "collect legacy metrics from runtime.MemStats with their old names")
+	flag.DurationVar(&c.ResyncInterval, "resync-interval", 5*time.Minute, "interval of reports with all metrics, between them only changed metrics are reported. Negative value disables it")
+	alwaysReport := flag.String("always-report", "", "comma separated list of metrics which are reported even if they are not changed")
+	flag.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 5*time.Second, "max duration of final report on shutdown")
+	flag.StringVar(&c.ListenAddress, "listen", "", "address for pull mode, metrics are served on /metrics and /metrics/json. Pull mode is disabled if it is empty")
+	flag.BoolVar(&c.DisablePush, "disable-push", false, "disable reports to server, metrics are available in pull mode only")
+	flag.BoolVar(&c.Once, "once", false, "run every collector once, send one report and exit with non-zero code if it was not delivered")
+	flag.BoolVar(&c.DryRun, "dry-run", false, "print requests to stdout instead of sending them to server")
+	flag.StringVar(&c.DryRunFormat, "dry-run-format", "text", "format of requests in dry run: text or json")
+	flag.StringVar(&c.RelabelFile, "relabel-file", "", "path to YAML file with relabel rules, prefix and static labels")
+	flag.StringVar(&c.MetricPrefix, "metric-prefix", "", "prefix for names of all metrics. It overrides prefix from relabel file")
+	labels := flag.String("labels", "", "comma separated list of static labels, e.g. host=$(hostname),env=prod. In push mode they are added to names of metrics like Alloc;env=prod;host=a")
+	flag.StringVar(&c.AllowMetrics, "allow-metrics", "", "regular expression for names of metrics which are reported, others are dropped")
+	flag.StringVar(&c.DenyMetrics, "deny-metrics", "", "regular expression for names of metrics which are dropped")
+	destinations := flag.String("destinations", "", "comma separated list of servers which receive reports, e.g. main=10.0.0.1:8080,backup=10.0.0.2:8080. Options and credentials of agent are used for all of them")
+	flag.StringVar(&c.DestinationsFile, "destinations-file", "", "path to YAML file with servers which receive reports and their own options. Credentials of agent are used only if inheritCredentials is set")
+	flag.BoolVar(&c.Failover, "failover", false, "send reports to the first available destination in order of list instead of all destinations")
+	aggregate := flag.String("aggregate", "", "comma separated list of gauges which are reported with min, max, avg and count of samples between reports, e.g. HeapAlloc,go_memstats_*")
+	flag.DurationVar(&c.CollectorTimeout, "collector-timeout", 0, "max duration of one collect. Poll interval of collector is used if it is empty")
+	flag.Parse()
+
+	c.HostFilesystems = config.SplitList(*hostFilesystems)
+	c.Collectors = config.SplitList(*collectors)
+	c.AlwaysReport = config.SplitList(*alwaysReport)
+	intervals := config.SplitList(*collectorIntervals)
+	labelList := config.SplitList(*labels)
+	destinationList := config.SplitList(*destinations)
+	c.Aggregate = config.SplitList(*aggregate)
+
+	// Environment variables have priority over flags
+	config.StringFromEnv(&c.Key, "KEY")
+	config.StringFromEnv(&c.CryptoKey, "CRYPTO_KEY")
+	config.StringFromEnv(&c.TLSCA, "TLS_CA")
+	config.StringFromEnv(&c.TLSCert, "TLS_CERT")
+	config.StringFromEnv(&c.TLSKey, "TLS_KEY")
+	config.StringFromEnv(&c.TLSServerName, "TLS_SERVER_NAME")
+	config.StringFromEnv(&c.Token, "TOKEN")
+	config.StringFromEnv(&c.TokenFile, "TOKEN_FILE")
+	config.StringFromEnv(&c.SpoolDir, "SPOOL_DIR")
+	config.StringFromEnv(&c.ProcRoot, "PROC_ROOT")
+	config.StringFromEnv(&c.SysRoot, "SYS_ROOT")
+	config.StringFromEnv(&c.ListenAddress, "LISTEN_ADDRESS")
+	config.StringsFromEnv(&c.HostFilesystems, "HOST_FILESYSTEMS")
+	config.StringsFromEnv(&c.Collectors, "COLLECTORS")
+	config.StringsFromEnv(&c.AlwaysReport, "ALWAYS_REPORT")
+	config.StringsFromEnv(&intervals, "COLLECTOR_INTERVALS")
+	config.StringFromEnv(&c.RelabelFile, "RELABEL_FILE")
+	config.StringFromEnv(&c.MetricPrefix, "METRIC_PREFIX")
+	config.StringsFromEnv(&labelList, "LABELS")
+	config.StringFromEnv(&c.AllowMetrics, "ALLOW_METRICS")
+	config.StringFromEnv(&c.DenyMetrics, "DENY_METRICS")
+	config.StringsFromEnv(&destinationList, "DESTINATIONS")
+	config.StringFromEnv(&c.DestinationsFile, "DESTINATIONS_FILE")
+	config.StringsFromEnv(&c.Aggregate, "AGGREGATE")
+
+	if err := config.IntFromEnv(&c.MaxRequestsPerMoment, "RATE_LIMIT"); err != nil {
+		log.Fatalf("failed to read configuration: %s", err)
+	}
+
+	if err := config.FloatFromEnv(&c.RequestsPerSecond, "REQUESTS_PER_SECOND"); err != nil {
+		log.Fatalf("failed to read configuration: %s", err)
+	}
+
+	parsedIntervals, err := config.ParseDurations(intervals)
+	if err != nil {
+		log.Fatalf("failed to parse intervals of collectors: %s", err)
+	}
+
+	c.CollectorIntervals = parsedIntervals
+
+	if c.Labels, err = config.ParseLabels(labelList); err != nil {
+		log.Fatalf("failed to parse labels: %s", err)
+	}
+
+	relabelConfig, err := newRelabelConfig(c)
+	if err != nil {
+		log.Fatalf("failed to read relabel config: %s", err)
+	}
+
+	c.Destinations = config.ParseDestinations(destinationList)
+
+	if c.DestinationsFile != "" {
+		list, err := config.ReadDestinations(c.DestinationsFile)
+		if err != nil {
+			log.Fatalf("failed to read destinations: %s", err)
+		}
+
+		c.Destinations = append(c.Destinations, list...)
+	}
+
+	a, err := metrics.New(metrics.WithConfig(metrics.Config{
 		ReportInterval:       c.ReportInterval,
 		PollInterval:         c.PollInterval,
 		Host:                 c.Host,
 		MaxRequestsPerMoment: c.MaxRequestsPerMoment,
+		QueueSize:            c.QueueSize,
+		RequestsPerSecond:    c.RequestsPerSecond,
+		Burst:                c.Burst,
 		Timeout:              c.Timeout,
 		MaxIdleConns:         c.MaxIdleConns,
-	})
+		DisableCompression:   c.DisableCompression,
+		Key:                  c.Key,
+		CryptoKey:            c.CryptoKey,
+		TLSCA:                c.TLSCA,
+		TLSCert:              c.TLSCert,
+		TLSKey:               c.TLSKey,
+		TLSServerName:        c.TLSServerName,
+		Token:                c.Token,
+		TokenFile:            c.TokenFile,
+		Retries:              c.Retries,
+		RetryInitialInterval: c.RetryInitialInterval,
+		RetryMaxInterval:     c.RetryMaxInterval,
+		SpoolDir:             c.SpoolDir,
+		SpoolMaxSize:         c.SpoolMaxSize,
+		SpoolMaxAge:          c.SpoolMaxAge,
+		ShutdownTimeout:      c.ShutdownTimeout,
+		ResyncInterval:       c.ResyncInterval,
+		AlwaysReport:         c.AlwaysReport,
+		ListenAddress:        c.ListenAddress,
+		DisablePush:          c.DisablePush,
+		DryRun:               c.DryRun,
+		DryRunFormat:         c.DryRunFormat,
+		Relabel:              relabelConfig,
+		Destinations:         newDestinations(c),
+		Failover:             c.Failover,
+		Aggregate:            c.Aggregate,
+	}))
 	if err != nil {
 		log.Fatalf("failed to create agent: %s", err)
 	}
 
-	// Channel for gracefully shutdown
-	exitCh := make(chan struct{})
-
-	// Starting new goroutine inside initMetrics
-	initMetrics(a, c.PollInterval, exitCh)
-
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	if err = registerCollectors(a, c); err != nil {
+		log.Fatalf("failed to register collectors: %s", err)
+	}
 
-	go func() {
-		// Starting agent cycle
-		a.Run()
-	}()
+	// Context is cancelled for gracefully shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	log.Println("agent started")
 
-	<-done
+	if c.Once {
+		if err = a.Once(ctx); err != nil {
+			stop()
+			log.Fatalf("report failed: %s", err)
+		}
 
-	go func() {
-		a.Shutdown()
-		log.Println("agent stopped")
-		close(exitCh)
-	}()
+		log.Println("report delivered")
+
+		return
+	}
 
-	<-exitCh
+	// Starting agent cycle. It returns after final report
+	if err = a.Run(ctx); err != nil {
+		stop()
+		log.Fatalf("agent stopped with error: %s", err)
+	}
 
 	log.Println("agent exited properly")
 }
 
-// initMetrics init all metrics and start job with runtime.MemStats in a separate goroutine
-func initMetrics(a *agent.Agent, pollInterval time.Duration, exitCh chan struct{}) {
-	var m = &runtime.MemStats{}
-
-	// Init runtime metrics
-	metricAlloc := agent.NewGauge(MetricAlloc, "")
-	metricBuckHashSys := agent.NewGauge(MetricBuckHashSys, "")
-	metricFrees := agent.NewGauge(MetricFrees, "")
-	metricGCCPUFracti := agent.NewGauge(MetricGCCPUFracti, "")
-	metricGCSys := agent.NewGauge(MetricGCSys, "")
-	metricHeapAlloc := agent.NewGauge(MetricHeapAlloc, "")
-	metricHeapIdle := agent.NewGauge(MetricHeapIdle, "")
-	metricHeapInuse := agent.NewGauge(MetricHeapInuse, "")
-	metricHeapObjects := agent.NewGauge(MetricHeapObjects, "")
-	metricHeapRelease := agent.NewGauge(MetricHeapRelease, "")
-	metricHeapSys := agent.NewGauge(MetricHeapSys, "")
-	metricLastGC := agent.NewGauge(MetricLastGC, "")
-	metricLookups := agent.NewGauge(MetricLookups, "")
-	metricMCacheInuse := agent.NewGauge(MetricMCacheInuse, "")
-	metricMCacheSys := agent.NewGauge(MetricMCacheSys, "")
-	metricMSpanInuse := agent.NewGauge(MetricMSpanInuse, "")
-	metricMSpanSys := agent.NewGauge(MetricMSpanSys, "")
-	metricMallocs := agent.NewGauge(MetricMallocs, "")
-	metricNextGC := agent.NewGauge(MetricNextGC, "")
-	metricNumForcedGC := agent.NewGauge(MetricNumForcedGC, "")
-	metricNumGC := agent.NewGauge(MetricNumGC, "")
-	metricOtherSys := agent.NewGauge(MetricOtherSys, "")
-	metricPauseTotalN := agent.NewGauge(MetricPauseTotalN, "")
-	metricStackInuse := agent.NewGauge(MetricStackInuse, "")
-	metricStackSys := agent.NewGauge(MetricStackSys, "")
-	metricSys := agent.NewGauge(MetricSys, "")
-	metricTotalAlloc := agent.NewGauge(MetricTotalAlloc, "")
-
-	// Init custom metrics
-	metricPollCount := agent.NewCounter(MetricPollCount, "")
-	metricRandomValue := agent.NewGauge(MetricRandomValue, "")
-
-	// Adding all metrics to track
-	a.Track(metricAlloc)
-	a.Track(metricBuckHashSys)
-	a.Track(metricFrees)
-	a.Track(metricGCCPUFracti)
-	a.Track(metricGCSys)
-	a.Track(metricHeapAlloc)
-	a.Track(metricHeapIdle)
-	a.Track(metricHeapInuse)
-	a.Track(metricHeapObjects)
-	a.Track(metricHeapRelease)
-	a.Track(metricHeapSys)
-	a.Track(metricLastGC)
-	a.Track(metricLookups)
-	a.Track(metricMCacheInuse)
-	a.Track(metricMCacheSys)
-	a.Track(metricMSpanInuse)
-	a.Track(metricMSpanSys)
-	a.Track(metricMallocs)
-	a.Track(metricNextGC)
-	a.Track(metricNumForcedGC)
-	a.Track(metricNumGC)
-	a.Track(metricOtherSys)
-	a.Track(metricPauseTotalN)
-	a.Track(metricStackInuse)
-	a.Track(metricStackSys)
-	a.Track(metricSys)
-	a.Track(metricTotalAlloc)
-	a.Track(metricPollCount)
-	a.Track(metricRandomValue)
-
-	// Ticker for cycle with collecting metrics
-	pollTicker := time.NewTicker(pollInterval)
-
-	go func() {
-		for {
-			select {
-			case <-exitCh:
-				pollTicker.Stop()
-				log.Println("main cycle with collecting metrics stopped")
-
-				return
-			case <-pollTicker.C:
-				runtime.ReadMemStats(m)
-				rand.Seed(time.Now().UTC().UnixNano())
-
-				metricAlloc.Set(float64(m.Alloc))
-				metricBuckHashSys.Set(float64(m.BuckHashSys))
-				metricFrees.Set(float64(m.Frees))
-				metricGCCPUFracti.Set(m.GCCPUFraction)
-				metricGCSys.Set(float64(m.GCSys))
-				metricHeapAlloc.Set(float64(m.HeapAlloc))
-				metricHeapIdle.Set(float64(m.HeapIdle))
-				metricHeapInuse.Set(float64(m.HeapInuse))
-				metricHeapObjects.Set(float64(m.HeapObjects))
-				metricHeapRelease.Set(float64(m.HeapReleased))
-				metricHeapSys.Set(float64(m.HeapSys))
-				metricLastGC.Set(float64(m.LastGC))
-				metricLookups.Set(float64(m.Lookups))
-				metricMCacheInuse.Set(float64(m.MCacheInuse))
-				metricMCacheSys.Set(float64(m.MCacheSys))
-				metricMSpanInuse.Set(float64(m.MSpanInuse))
-				metricMSpanSys.Set(float64(m.MSpanSys))
-				metricMallocs.Set(float64(m.Mallocs))
-				metricNextGC.Set(float64(m.NextGC))
-				metricNumForcedGC.Set(float64(m.NumForcedGC))
-				metricNumGC.Set(float64(m.NumGC))
-				metricOtherSys.Set(float64(m.OtherSys))
-				metricPauseTotalN.Set(float64(m.PauseTotalNs))
-				metricStackInuse.Set(float64(m.StackInuse))
-				metricStackSys.Set(float64(m.StackSys))
-				metricSys.Set(float64(m.Sys))
-				metricTotalAlloc.Set(float64(m.TotalAlloc))
-
-				metricPollCount.Inc()
-				metricRandomValue.Set(float64(rand.Int63()))
+// defaultCollectors returning collectors which are enabled by default.
+// Host metrics are read from /proc, so they are available only on Linux
+func defaultCollectors() string {
+	if runtime.GOOS == "linux" {
+		return metrics.RuntimeCollectorName + "," + metrics.HostCollectorName
+	}
+
+	return metrics.RuntimeCollectorName
+}
+
+// registerCollectors adding enabled collectors to agent
+func registerCollectors(a *metrics.Agent, c *config.AgentConfig) error {
+	for _, name := range c.Collectors {
+		var col metrics.Collector
+
+		switch name {
+		case metrics.RuntimeCollectorName:
+			col = metrics.NewRuntimeCollector(&metrics.RuntimeConfig{
+				Compat: c.RuntimeCompat,
+			})
+		case metrics.HostCollectorName:
+			col = metrics.NewHostCollector(&metrics.HostConfig{
+				ProcRoot:    c.ProcRoot,
+				SysRoot:     c.SysRoot,
+				Filesystems: c.HostFilesystems,
+			})
+		default:
+			return fmt.Errorf("unknown collector %q", name)
+		}
+
+		err := a.Register(col, &metrics.CollectorConfig{
+			Interval: c.CollectorIntervals[name],
+			Timeout:  c.CollectorTimeout,
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Printf("collector %s is enabled\n", name)
+	}
+
+	return nil
+}
+
+// newRelabelConfig merging relabel file with flags. Deny and allow rules are applied
+// before rules from file, so they match original names. Returns nil if relabeling is not configured
+func newRelabelConfig(c *config.AgentConfig) (*metrics.RelabelConfig, error) {
+	rc := &relabel.Config{}
+
+	if c.RelabelFile != "" {
+		var err error
+
+		if rc, err = relabel.Read(c.RelabelFile); err != nil {
+			return nil, err
+		}
+	}
+
+	rules := make([]relabel.Rule, 0, len(rc.Rules)+2)
+
+	if c.DenyMetrics != "" {
+		rules = append(rules, relabel.Rule{Action: relabel.ActionDrop, Regex: c.DenyMetrics})
+	}
+
+	if c.AllowMetrics != "" {
+		rules = append(rules, relabel.Rule{Action: relabel.ActionKeep, Regex: c.AllowMetrics})
+	}
+
+	rc.Rules = append(rules, rc.Rules...)
+
+	if c.MetricPrefix != "" {
+		rc.Prefix = c.MetricPrefix
+	}
+
+	if len(c.Labels) > 0 && rc.Labels == nil {
+		rc.Labels = make(map[string]string, len(c.Labels))
+	}
+
+	for name, value := range c.Labels {
+		rc.Labels[name] = value
+	}
+
+	if len(rc.Rules) == 0 && rc.Prefix == "" && len(rc.Labels) == 0 {
+		return nil, nil
+	}
+
+	result := &metrics.RelabelConfig{
+		Rules:  make([]metrics.RelabelRule, 0, len(rc.Rules)),
+		Prefix: rc.Prefix,
+		Labels: rc.Labels,
+	}
+
+	for _, r := range rc.Rules {
+		result.Rules = append(result.Rules, metrics.RelabelRule{
+			Action:      r.Action,
+			Regex:       r.Regex,
+			Replacement: r.Replacement,
+		})
+	}
+
+	return result, nil
+}
+
+// newDestinations mapping destinations from configuration. Destination without its own
+// spool directory gets subdirectory of spool directory of agent, in failover mode
+// only the first destination has spool
+func newDestinations(c *config.AgentConfig) []metrics.Destination {
+	result := make([]metrics.Destination, 0, len(c.Destinations))
+
+	for i, d := range c.Destinations {
+		spoolDir := d.SpoolDir
+
+		if spoolDir == "" && c.SpoolDir != "" {
+			switch {
+			case !c.Failover:
+				spoolDir = filepath.Join(c.SpoolDir, spoolName(d))
+			case i == 0:
+				spoolDir = c.SpoolDir
 			}
 		}
-	}()
+
+		result = append(result, metrics.Destination{
+			Name:                 d.Name,
+			Host:                 d.Host,
+			MaxRequestsPerMoment: d.MaxRequestsPerMoment,
+			QueueSize:            d.QueueSize,
+			RequestsPerSecond:    d.RequestsPerSecond,
+			Burst:                d.Burst,
+			Timeout:              d.Timeout,
+			MaxIdleConns:         d.MaxIdleConns,
+			DisableCompression:   d.DisableCompression,
+			InheritCredentials:   d.InheritCredentials,
+			Key:                  d.Key,
+			CryptoKey:            d.CryptoKey,
+			TLSCA:                d.TLSCA,
+			TLSCert:              d.TLSCert,
+			TLSKey:               d.TLSKey,
+			TLSServerName:        d.TLSServerName,
+			Token:                d.Token,
+			TokenFile:            d.TokenFile,
+			Retries:              d.Retries,
+			RetryInitialInterval: d.RetryInitialInterval,
+			RetryMaxInterval:     d.RetryMaxInterval,
+			SpoolDir:             spoolDir,
+			SpoolMaxSize:         d.SpoolMaxSize,
+			SpoolMaxAge:          d.SpoolMaxAge,
+		})
+	}
+
+	return result
+}
+
+// spoolName returning name of spool directory of destination, e.g. "10.0.0.1_8080"
+func spoolName(d config.DestinationConfig) string {
+	name := d.Name
+	if name == "" {
+		name = d.Host
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+
+		return '_'
+	}, name)
 }
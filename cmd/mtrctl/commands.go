@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtrrun/pkg/client"
+)
+
+// clearScreen moving cursor to top left corner and clearing terminal
+const clearScreen = "\033[H\033[2J"
+
+func runGet(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	m, err := c.Get(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, formatValue(m))
+
+	return err
+}
+
+func runSet(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	v, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return fmt.Errorf("%w: value must be float", errUsage)
+	}
+
+	m, err := c.Update(ctx, client.Gauge(args[0], v))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, formatValue(m))
+
+	return err
+}
+
+func runInc(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errUsage
+	}
+
+	delta := int64(1)
+
+	if len(args) == 2 {
+		var err error
+
+		if delta, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return fmt.Errorf("%w: delta must be integer", errUsage)
+		}
+	}
+
+	m, err := c.Update(ctx, client.Counter(args[0], delta))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, formatValue(m))
+
+	return err
+}
+
+func runDelete(ctx context.Context, c *client.Client, _ io.Writer, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	return c.Delete(ctx, args[0], args[1])
+}
+
+func runPing(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	start := time.Now()
+
+	if err := c.Ping(ctx); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "OK %s\n", time.Since(start).Round(time.Millisecond))
+
+	return err
+}
+
+func runList(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	f := &filter{}
+	fs.StringVar(&f.mType, "type", "", "type of metrics")
+	fs.StringVar(&f.prefix, "prefix", "", "prefix of names")
+	match := fs.String("match", "", "regular expression for names")
+	format := fs.String("o", formatTable, "output format: table, json or csv")
+
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+
+	if *match != "" {
+		re, err := regexp.Compile(*match)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errUsage, err)
+		}
+
+		f.match = re
+	}
+
+	w, err := newWriter(*format)
+	if err != nil {
+		return err
+	}
+
+	list, err := c.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	return w(out, f.apply(list))
+}
+
+func runWatch(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	f := &filter{}
+	interval := fs.Duration("interval", 2*time.Second, "refresh interval")
+	count := fs.Int("count", 0, "count of refreshes. Watch is stopped by interrupt if it is empty")
+	fs.StringVar(&f.prefix, "prefix", "", "prefix of names")
+
+	if err := fs.Parse(args); err != nil || *interval <= 0 {
+		return errUsage
+	}
+
+	f.names = fs.Args()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	// Previous values for changes of metrics between refreshes
+	prev := make(map[string]client.Metric)
+
+	for i := 1; ; i++ {
+		list, err := c.List(ctx)
+		if err != nil {
+			return err
+		}
+
+		list = f.apply(list)
+
+		if _, err = fmt.Fprintf(out, "%sEvery %s: %s\n\n", clearScreen, *interval, time.Now().Format(time.RFC3339)); err != nil {
+			return err
+		}
+
+		if err = writeWatch(out, list, prev); err != nil {
+			return err
+		}
+
+		for _, m := range list {
+			prev[m.MType+"/"+m.ID] = m
+		}
+
+		if *count > 0 && i >= *count {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// filter of metrics for list and watch. Empty fields match everything
+type filter struct {
+	mType  string
+	prefix string
+	match  *regexp.Regexp
+	names  []string
+}
+
+func (f *filter) apply(list []client.Metric) []client.Metric {
+	result := make([]client.Metric, 0, len(list))
+
+	for _, m := range list {
+		if f.mType != "" && m.MType != f.mType {
+			continue
+		}
+
+		if !strings.HasPrefix(m.ID, f.prefix) {
+			continue
+		}
+
+		if f.match != nil && !f.match.MatchString(m.ID) {
+			continue
+		}
+
+		if len(f.names) > 0 && !contains(f.names, m.ID) {
+			continue
+		}
+
+		result = append(result, m)
+	}
+
+	return result
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,269 @@
+// Command mtrctl queries and manages metrics on server.
+//
+// Usage:
+//
+//	mtrctl [flags] <command> [command flags] [arguments]
+//
+// Server address and credentials are read from config file, flags and environment
+// variables with prefix MTRCTL_. Environment variables have priority over flags,
+// flags have priority over file
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mtrrun/internal/config"
+	"github.com/mtrrun/internal/tlsconfig"
+	"github.com/mtrrun/pkg/client"
+)
+
+const (
+	defaultAddress    = "127.0.0.1:8080"
+	defaultTimeout    = 10 * time.Second
+	defaultConfigName = ".mtrctl.yaml"
+)
+
+// errUsage is returned for invalid arguments, usage is printed for it
+var errUsage = errors.New("invalid arguments")
+
+// command of mtrctl
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, c *client.Client, out io.Writer, args []string) error
+}
+
+var commands = []command{
+	{name: "get", usage: "get <gauge|counter> <name>\n\tprint value of metric", run: runGet},
+	{name: "set", usage: "set <name> <value>\n\tset value of gauge", run: runSet},
+	{name: "inc", usage: "inc <name> [delta]\n\tadd delta to counter, delta is 1 by default", run: runInc},
+	{name: "list", usage: "list [-type gauge|counter] [-prefix prefix] [-match regexp] [-o table|json|csv]\n\tprint metrics", run: runList},
+	{name: "delete", usage: "delete <gauge|counter> <name>\n\tdelete metric, admin token is required", run: runDelete},
+	{name: "watch", usage: "watch [-interval 2s] [-count n] [-prefix prefix] [name...]\n\trefresh view of selected metrics until interrupted", run: runWatch},
+	{name: "ping", usage: "ping\n\tcheck that server is available", run: runPing},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executing command and returning exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("mtrctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	path := fs.String("config", "", "path to YAML config file. Default is ~/"+defaultConfigName+" if it exists")
+	address := fs.String("a", defaultAddress, "address of server, e.g. 127.0.0.1:8080 or https://metrics.local")
+	key := fs.String("k", "", "key for HMAC-SHA256 signing of requests")
+	cryptoKey := fs.String("crypto-key", "", "path to PEM file with public key of server for encryption of requests")
+	token := fs.String("token", "", "bearer token for server API")
+	tokenFile := fs.String("token-file", "", "path to file with bearer token")
+	tlsCA := fs.String("tls-ca", "", "path to PEM file with CA certificates for verifying server")
+	tlsCert := fs.String("tls-cert", "", "path to PEM file with client certificate for mutual TLS")
+	tlsKey := fs.String("tls-key", "", "path to PEM file with client key for mutual TLS")
+	tlsServerName := fs.String("tls-server-name", "", "server name in certificate if it differs from address")
+	timeout := fs.Duration("timeout", defaultTimeout, "timeout of one request")
+
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: mtrctl [flags] <command> [command flags] [arguments]\n\nCommands:")
+
+		for _, cmd := range commands {
+			fmt.Fprintf(stderr, "  %s\n", cmd.usage)
+		}
+
+		fmt.Fprintln(stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config.StringFromEnv(path, "MTRCTL_CONFIG")
+
+	c, err := readConfig(*path)
+	if err != nil {
+		fmt.Fprintf(stderr, "mtrctl: unable to read config: %s\n", err)
+
+		return 1
+	}
+
+	// Flags which are set explicitly override config file
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "a":
+			c.Address = *address
+		case "k":
+			c.Key = *key
+		case "crypto-key":
+			c.CryptoKey = *cryptoKey
+		case "token":
+			c.Token = *token
+		case "token-file":
+			c.TokenFile = *tokenFile
+		case "tls-ca":
+			c.TLSCA = *tlsCA
+		case "tls-cert":
+			c.TLSCert = *tlsCert
+		case "tls-key":
+			c.TLSKey = *tlsKey
+		case "tls-server-name":
+			c.TLSServerName = *tlsServerName
+		}
+	})
+
+	// Environment variables have priority over flags. They have prefix, so variables
+	// of agent in the same shell don't send credentials to other server
+	config.StringFromEnv(&c.Address, "MTRCTL_ADDRESS")
+	config.StringFromEnv(&c.Key, "MTRCTL_KEY")
+	config.StringFromEnv(&c.CryptoKey, "MTRCTL_CRYPTO_KEY")
+	config.StringFromEnv(&c.Token, "MTRCTL_TOKEN")
+	config.StringFromEnv(&c.TokenFile, "MTRCTL_TOKEN_FILE")
+	config.StringFromEnv(&c.TLSCA, "MTRCTL_TLS_CA")
+	config.StringFromEnv(&c.TLSCert, "MTRCTL_TLS_CERT")
+	config.StringFromEnv(&c.TLSKey, "MTRCTL_TLS_KEY")
+	config.StringFromEnv(&c.TLSServerName, "MTRCTL_TLS_SERVER_NAME")
+
+	if c.Address == "" {
+		c.Address = defaultAddress
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+
+		return 2
+	}
+
+	var cmd *command
+
+	for i := range commands {
+		if commands[i].name == fs.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+
+	if cmd == nil {
+		fmt.Fprintf(stderr, "mtrctl: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+
+		return 2
+	}
+
+	cl, err := newClient(c, *timeout)
+	if err != nil {
+		fmt.Fprintf(stderr, "mtrctl: %s\n", err)
+
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err = cmd.run(ctx, cl, stdout, fs.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "mtrctl: %s\nUsage: mtrctl %s\n", err, cmd.usage)
+
+			return 2
+		}
+
+		fmt.Fprintf(stderr, "mtrctl %s: %s\n", cmd.name, err)
+
+		return 1
+	}
+
+	return 0
+}
+
+// readConfig reading config file. Default file is optional
+func readConfig(path string) (*config.CtlConfig, error) {
+	if path != "" {
+		return config.ReadCtlConfig(path)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return &config.CtlConfig{}, nil
+	}
+
+	c, err := config.ReadCtlConfig(filepath.Join(home, defaultConfigName))
+	if errors.Is(err, os.ErrNotExist) {
+		return &config.CtlConfig{}, nil
+	}
+
+	return c, err
+}
+
+// newClient creating client for server with credentials from configuration
+func newClient(c *config.CtlConfig, timeout time.Duration) (*client.Client, error) {
+	token := c.Token
+
+	if c.TokenFile != "" {
+		b, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read token: %w", err)
+		}
+
+		token = strings.TrimSpace(string(b))
+	}
+
+	hooks := make([]client.Hook, 0, 3)
+
+	if token != "" {
+		hooks = append(hooks, client.BearerToken(token))
+	}
+
+	if c.Key != "" {
+		hooks = append(hooks, client.HMAC(c.Key))
+	}
+
+	// Encryption is after signing, server verifies signature of decrypted body
+	if c.CryptoKey != "" {
+		encrypt, err := client.EncryptFile(c.CryptoKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read public key: %w", err)
+		}
+
+		hooks = append(hooks, encrypt)
+	}
+
+	address := c.Address
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if c.TLSCA != "" || c.TLSCert != "" || c.TLSKey != "" || c.TLSServerName != "" || strings.HasPrefix(address, "https://") {
+		tlsConfig, err := tlsconfig.Client(&tlsconfig.ClientConfig{
+			CAFile:     c.TLSCA,
+			CertFile:   c.TLSCert,
+			KeyFile:    c.TLSKey,
+			ServerName: c.TLSServerName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure TLS: %w", err)
+		}
+
+		transport.TLSClientConfig = tlsConfig
+
+		if !strings.Contains(address, "://") {
+			address = "https://" + address
+		}
+	}
+
+	return client.New(&client.Config{
+		Address: address,
+		HTTPClient: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+		Hooks: hooks,
+	}), nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mtrrun/internal/handler"
+	"github.com/mtrrun/internal/repository"
+	"github.com/mtrrun/internal/service"
+)
+
+func newTestServer(t *testing.T) string {
+	r := mux.NewRouter()
+
+	handler.New(&handler.Config{
+		Router: r,
+		MetSrv: service.NewMetricService(&service.MetricServiceConfig{
+			MetRepo: repository.NewMetricMemCache(),
+		}),
+	})
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+// mtrctl running command against server and returning exit code and output
+func mtrctl(t *testing.T, address string, args ...string) (int, string) {
+	t.Setenv("MTRCTL_CONFIG", "")
+	t.Setenv("HOME", t.TempDir())
+
+	// Variables of environment must not change flags in tests
+	for _, name := range []string{
+		"MTRCTL_ADDRESS", "MTRCTL_KEY", "MTRCTL_CRYPTO_KEY", "MTRCTL_TOKEN", "MTRCTL_TOKEN_FILE",
+		"MTRCTL_TLS_CA", "MTRCTL_TLS_CERT", "MTRCTL_TLS_KEY", "MTRCTL_TLS_SERVER_NAME",
+	} {
+		t.Setenv(name, "")
+		require.NoError(t, os.Unsetenv(name))
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	code := run(append([]string{"-a", address}, args...), &stdout, &stderr)
+
+	return code, stdout.String() + stderr.String()
+}
+
+func TestCommands(t *testing.T) {
+	address := newTestServer(t)
+
+	code, out := mtrctl(t, address, "ping")
+	require.Equal(t, 0, code, out)
+
+	code, out = mtrctl(t, address, "set", "Alloc", "1.5")
+	require.Equal(t, 0, code, out)
+	require.Equal(t, "1.5\n", out)
+
+	mtrctl(t, address, "inc", "Requests")
+	code, out = mtrctl(t, address, "inc", "Requests", "4")
+	require.Equal(t, 0, code, out)
+	require.Equal(t, "5\n", out)
+
+	code, out = mtrctl(t, address, "get", "counter", "Requests")
+	require.Equal(t, 0, code, out)
+	require.Equal(t, "5\n", out)
+
+	code, out = mtrctl(t, address, "list", "-o", "csv")
+	require.Equal(t, 0, code, out)
+	require.Equal(t, "name,type,value\nRequests,counter,5\nAlloc,gauge,1.5\n", out)
+
+	code, out = mtrctl(t, address, "list", "-type", "gauge")
+	require.Equal(t, 0, code, out)
+	require.Equal(t, "NAME   TYPE   VALUE\nAlloc  gauge  1.5\n", out)
+
+	code, out = mtrctl(t, address, "watch", "-count", "1", "-interval", "1ms", "Requests")
+	require.Equal(t, 0, code, out)
+	require.Contains(t, out, "Requests  counter  5")
+	require.NotContains(t, out, "Alloc")
+
+	code, _ = mtrctl(t, address, "delete", "gauge", "Alloc")
+	require.Equal(t, 0, code)
+
+	code, out = mtrctl(t, address, "get", "gauge", "Alloc")
+	require.Equal(t, 1, code)
+	require.Contains(t, out, "404")
+}
+
+func TestEnvironment(t *testing.T) {
+	address := newTestServer(t)
+
+	// Variables of agent don't change server of mtrctl
+	t.Setenv("ADDRESS", "127.0.0.1:1")
+	t.Setenv("TOKEN", "agent-token")
+
+	code, out := mtrctl(t, address, "ping")
+	require.Equal(t, 0, code, out)
+
+	// Variable of mtrctl has priority over flag
+	t.Setenv("MTRCTL_ADDRESS", "127.0.0.1:1")
+
+	var stdout, stderr bytes.Buffer
+
+	require.Equal(t, 1, run([]string{"-a", address, "ping"}, &stdout, &stderr))
+}
+
+func TestUsageErrors(t *testing.T) {
+	address := newTestServer(t)
+
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"get", "gauge"},
+		{"set", "Alloc", "abc"},
+		{"list", "-o", "xml"},
+		{"list", "-match", "("},
+	} {
+		code, out := mtrctl(t, address, args...)
+		require.Equal(t, 2, code, strings.Join(args, " ")+": "+out)
+	}
+}
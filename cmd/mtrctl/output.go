@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/mtrrun/pkg/client"
+)
+
+// Output formats of list
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+type writer func(out io.Writer, list []client.Metric) error
+
+func newWriter(format string) (writer, error) {
+	switch format {
+	case formatTable:
+		return writeTable, nil
+	case formatJSON:
+		return writeJSON, nil
+	case formatCSV:
+		return writeCSV, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown output format %q", errUsage, format)
+	}
+}
+
+func writeTable(out io.Writer, list []client.Metric) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "NAME\tTYPE\tVALUE")
+
+	for _, m := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", m.ID, m.MType, formatValue(m))
+	}
+
+	return tw.Flush()
+}
+
+func writeJSON(out io.Writer, list []client.Metric) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(list)
+}
+
+func writeCSV(out io.Writer, list []client.Metric) error {
+	w := csv.NewWriter(out)
+
+	if err := w.Write([]string{"name", "type", "value"}); err != nil {
+		return err
+	}
+
+	for _, m := range list {
+		if err := w.Write([]string{m.ID, m.MType, formatValue(m)}); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+// writeWatch writing table with changes since previous refresh
+func writeWatch(out io.Writer, list []client.Metric, prev map[string]client.Metric) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "NAME\tTYPE\tVALUE\tCHANGE")
+
+	for _, m := range list {
+		change := ""
+
+		if p, ok := prev[m.MType+"/"+m.ID]; ok {
+			change = formatChange(p, m)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.ID, m.MType, formatValue(m), change)
+	}
+
+	return tw.Flush()
+}
+
+// formatValue formatting value of metric like server does
+func formatValue(m client.Metric) string {
+	switch {
+	case m.Delta != nil:
+		return strconv.FormatInt(*m.Delta, 10)
+	case m.Value != nil:
+		return strconv.FormatFloat(*m.Value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// formatChange formatting difference between values with sign
+func formatChange(prev, cur client.Metric) string {
+	switch {
+	case prev.Delta != nil && cur.Delta != nil:
+		return fmt.Sprintf("%+d", *cur.Delta-*prev.Delta)
+	case prev.Value != nil && cur.Value != nil:
+		diff := *cur.Value - *prev.Value
+		if diff >= 0 {
+			return "+" + strconv.FormatFloat(diff, 'f', -1, 64)
+		}
+
+		return strconv.FormatFloat(diff, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -10,9 +12,14 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mtrrun/internal/auth"
+	"github.com/mtrrun/internal/config"
+	"github.com/mtrrun/internal/envelope"
 	"github.com/mtrrun/internal/handler"
+	"github.com/mtrrun/internal/middleware"
 	"github.com/mtrrun/internal/repository"
 	"github.com/mtrrun/internal/service"
+	"github.com/mtrrun/internal/tlsconfig"
 )
 
 // TODO: will be remove how project starts  to use config
@@ -21,8 +28,103 @@ var (
 )
 
 func main() {
+	c := &config.ServerConfig{
+		Address: defaultAddr,
+	}
+
+	flag.StringVar(&c.Key, "k", "", "key for HMAC-SHA256 signing of metrics. Signing is disabled if key is empty")
+	cryptoKeys := flag.String("crypto-key", "", "comma separated paths to PEM files with private keys for decryption of metrics")
+	flag.StringVar(&c.TLSCert, "tls-cert", "", "path to PEM file with server certificate. Enables TLS with -tls-key")
+	flag.StringVar(&c.TLSKey, "tls-key", "", "path to PEM file with server key. Enables TLS with -tls-cert")
+	flag.StringVar(&c.TLSMinVersion, "tls-min-version", "1.2", "minimal TLS version: 1.0, 1.1, 1.2 or 1.3")
+	cipherSuites := flag.String("tls-ciphers", "", "comma separated names of allowed cipher suites for TLS 1.2 and lower")
+	flag.StringVar(&c.TLSClientCA, "tls-client-ca", "", "path to PEM file with CA for client certificates. Enables mutual TLS")
+	trustedSubnet := flag.String("t", "", "comma separated subnets in CIDR notation allowed to write metrics")
+	trustedSubnetRead := flag.String("t-read", "", "comma separated subnets in CIDR notation allowed to read metrics")
+	trustedProxies := flag.String("trusted-proxies", "", "comma separated addresses of proxies allowed to set X-Forwarded-For and X-Real-IP")
+	flag.StringVar(&c.AuthTokens, "auth-tokens", "", "path to YAML file with API tokens. Authentication is disabled if empty")
+	flag.Parse()
+
+	c.CryptoKeys = config.SplitList(*cryptoKeys)
+	c.TLSCipherSuites = config.SplitList(*cipherSuites)
+	c.TrustedSubnet = config.SplitList(*trustedSubnet)
+	c.TrustedSubnetRead = config.SplitList(*trustedSubnetRead)
+	c.TrustedProxies = config.SplitList(*trustedProxies)
+
+	// Environment variables have priority over flags
+	config.StringFromEnv(&c.Key, "KEY")
+	config.StringsFromEnv(&c.CryptoKeys, "CRYPTO_KEY")
+	config.StringFromEnv(&c.TLSCert, "TLS_CERT")
+	config.StringFromEnv(&c.TLSKey, "TLS_KEY")
+	config.StringFromEnv(&c.TLSMinVersion, "TLS_MIN_VERSION")
+	config.StringsFromEnv(&c.TLSCipherSuites, "TLS_CIPHERS")
+	config.StringFromEnv(&c.TLSClientCA, "TLS_CLIENT_CA")
+	config.StringsFromEnv(&c.TrustedSubnet, "TRUSTED_SUBNET")
+	config.StringsFromEnv(&c.TrustedSubnetRead, "TRUSTED_SUBNET_READ")
+	config.StringsFromEnv(&c.TrustedProxies, "TRUSTED_PROXIES")
+	config.StringFromEnv(&c.AuthTokens, "AUTH_TOKENS")
+
+	trustedProxyNets, err := middleware.ParseCIDRs(c.TrustedProxies)
+	if err != nil {
+		log.Fatalf("unable to parse trusted proxies: %s", err)
+	}
+
+	writeSubnets, err := middleware.ParseCIDRs(c.TrustedSubnet)
+	if err != nil {
+		log.Fatalf("unable to parse trusted subnet: %s", err)
+	}
+
+	readSubnets, err := middleware.ParseCIDRs(c.TrustedSubnetRead)
+	if err != nil {
+		log.Fatalf("unable to parse trusted subnet for reading: %s", err)
+	}
+
+	var tokens *auth.Store
+
+	if c.AuthTokens != "" {
+		tokens, err = auth.ReadStore(c.AuthTokens)
+		if err != nil {
+			log.Fatalf("unable to read API tokens: %s", err)
+		}
+	}
+
+	var decrypter *envelope.Decrypter
+
+	if len(c.CryptoKeys) > 0 {
+		keys := make([]*rsa.PrivateKey, 0, len(c.CryptoKeys))
+
+		for _, path := range c.CryptoKeys {
+			key, err := envelope.ReadPrivateKey(path)
+			if err != nil {
+				log.Fatalf("unable to read private key: %s", err)
+			}
+
+			keys = append(keys, key)
+		}
+
+		decrypter = envelope.NewDecrypter(keys...)
+	}
+
+	decryptConfig := &middleware.DecryptConfig{
+		Decrypter: decrypter,
+	}
+
 	r := mux.NewRouter()
 
+	// Identity of agent from client certificate for mutual TLS
+	r.Use(middleware.Identity)
+
+	// Decryption of request bodies encrypted by agents
+	r.Use(middleware.Decrypt(decryptConfig))
+
+	// Compression of request and response bodies
+	r.Use(middleware.Gzip(&middleware.GzipConfig{}))
+
+	// Verifying signatures of requests and signing responses
+	r.Use(middleware.Hash(&middleware.HashConfig{
+		Key: c.Key,
+	}))
+
 	// Create repository layer
 	metCache := repository.NewMetricMemCache()
 
@@ -35,22 +137,80 @@ func main() {
 	handler.New(&handler.Config{
 		Router: r,
 		MetSrv: metSrv,
+		ReadMiddlewares: []mux.MiddlewareFunc{
+			middleware.TrustedSubnet(&middleware.TrustedSubnetConfig{
+				Subnets:        readSubnets,
+				TrustedProxies: trustedProxyNets,
+			}),
+			middleware.Auth(&middleware.AuthConfig{
+				Store: tokens,
+				Scope: auth.ScopeRead,
+			}),
+		},
+		WriteMiddlewares: []mux.MiddlewareFunc{
+			middleware.TrustedSubnet(&middleware.TrustedSubnetConfig{
+				Subnets:        writeSubnets,
+				TrustedProxies: trustedProxyNets,
+			}),
+			middleware.Auth(&middleware.AuthConfig{
+				Store: tokens,
+				Scope: auth.ScopeWrite,
+			}),
+			// Only encrypted metrics are accepted when server has private keys
+			middleware.RequireEncryption(decryptConfig),
+		},
+		AdminMiddlewares: []mux.MiddlewareFunc{
+			middleware.TrustedSubnet(&middleware.TrustedSubnetConfig{
+				Subnets:        writeSubnets,
+				TrustedProxies: trustedProxyNets,
+			}),
+			middleware.Auth(&middleware.AuthConfig{
+				Store: tokens,
+				Scope: auth.ScopeAdmin,
+			}),
+			// Encryption is not required, admin requests have no body with metrics.
+			// Admin token and signature by key protect them
+		},
 	})
 
 	srv := &http.Server{
-		Addr:    defaultAddr,
+		Addr:    c.Address,
 		Handler: r,
 	}
 
+	if c.TLSCert != "" || c.TLSKey != "" {
+		tlsConfig, err := tlsconfig.Server(&tlsconfig.ServerConfig{
+			CertFile:     c.TLSCert,
+			KeyFile:      c.TLSKey,
+			MinVersion:   c.TLSMinVersion,
+			CipherSuites: c.TLSCipherSuites,
+			ClientCAFile: c.TLSClientCA,
+		})
+		if err != nil {
+			log.Fatalf("unable to configure TLS: %s", err)
+		}
+
+		srv.TLSConfig = tlsConfig
+	}
+
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+
+		// Certificates are taken from TLSConfig, so file names are empty
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen: %s\n", err)
 		}
 	}()
-	log.Printf("starting server on %q addr", defaultAddr)
+	log.Printf("starting server on %q addr", c.Address)
 
 	<-done
 	log.Print("server stopped")
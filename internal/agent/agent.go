@@ -1,18 +1,24 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"net/http"
+	"net"
+	"os"
 	"sync"
 	"time"
+
+	"github.com/mtrrun/internal/relabel"
 )
 
 // Metric is interface for
 // all metric types.
 type Metric interface {
 	Desc() Description
-	GetValue() string
+	Value() Value
 }
 
 // Tracker is interface which declarate methods for
@@ -29,6 +35,7 @@ type Tracker interface {
 type Counter interface {
 	Metric
 	Inc()
+	Add(int64)
 }
 
 // Gauge is analog from Prometheus library
@@ -42,9 +49,13 @@ type Gauge interface {
 	Sub(float64)
 }
 
+// Histogram is distribution of values, e.g. from runtime/metrics.
+// Server has no histograms, so it is reported as gauges with quantiles and counter with count
 type Histogram interface {
 	Metric
-	// TODO: do it in next time
+	Set(counts []uint64, buckets []float64)
+	Quantile(q float64) float64
+	Count() uint64
 }
 
 type Summary interface {
@@ -60,7 +71,7 @@ type Logger interface {
 
 // Client interface for client that sending metrics to other service
 type Client interface {
-	DoRequest(method string, url string, header map[string]string, body []byte) error
+	DoRequest(ctx context.Context, method string, url string, header map[string]string, body []byte) error
 	Shutdown()
 }
 
@@ -74,16 +85,21 @@ type Description struct {
 type Status struct {
 	Name       string
 	MetricType string
-	Value      string
+	Value      Value
+	Help       string
 }
 
 const (
 	defaultReportInterval       = 2
 	defaultPollInterval         = 10
 	defaultMaxRequestsPerMoment = 5
+	defaultQueueSize            = 100
+	defaultShutdownTimeout      = 5 * time.Second
+	defaultResyncInterval       = 5 * time.Minute
+	defaultRetries              = 3
 
 	contentTypeHeader  = "Content-Type"
-	defaultContentType = "text/plain"
+	defaultContentType = "application/json"
 
 	gaugeType   = "gauge"
 	counterType = "counter"
@@ -97,8 +113,8 @@ type Agent struct {
 	// Container with metrics
 	container Tracker
 
-	// Client for sending request with metrics to other services
-	client Client
+	// Collectors which update metrics in container
+	collectors *Registry
 
 	// Time interval in seconds for sending request to other service.
 	// If reportInterval is empty that will be use default value - 2 second.
@@ -108,26 +124,157 @@ type Agent struct {
 	// If pollInterval is empty that will be use default value - 10 second.
 	pollInterval time.Duration
 
-	// Channel and sync.Once for gracefully shutdown
+	// Channel and sync.Once for gracefully shutdown.
+	// running is waited on shutdown, so final report is finished before resources are closed
 	exit       chan struct{}
+	onceExit   sync.Once
 	onceCloser sync.Once
+	runMu      sync.Mutex
+	started    bool
+	running    sync.WaitGroup
+
+	// Max duration of final report on shutdown
+	shutdownTimeout time.Duration
+
+	// Address of HTTP server for pull mode, empty if it is disabled
+	listenAddress string
+	disablePush   bool
+
+	// Rules for names of reported metrics and static labels. Nil if names are not changed
+	relabeler *relabel.Relabeler
+
+	// Metrics which were dropped because of collision of names after relabeling, it is logged once
+	collisionsMu sync.Mutex
+	collisions   map[string]struct{}
 
-	host                 string
-	maxRequestsPerMoment int
+	// Samples of gauges between reports. Nil if aggregation is disabled
+	aggregator *aggregator
+
+	// Servers which receive reports
+	dests []*destination
+
+	// Independent streams of reports. In fan-out mode every destination has its own route,
+	// in failover mode there is one route with all destinations
+	routes []*route
 }
 
 // Config configuration list for Agent
 type Config struct {
 	//Container      Tracker
-	//Client         Client
+
+	// Servers which receive reports. Options below are used for every destination
+	// which doesn't set its own value, except credentials, see Destination.InheritCredentials.
+	// If Destinations is empty that will be use one destination with Host and options below
+	Destinations []Destination
+
+	// Failover sends report to the first destination and metrics which were not delivered
+	// to the next one in order of Destinations. Only the first destination could have spool.
+	// Else report is sent to every destination
+	Failover bool
+
+	// Client for sending reports. If Client is empty that will be use http client
+	// configured with options below
+	Client Client
+
 	ReportInterval time.Duration
 	PollInterval   time.Duration
 
-	Host                 string
+	Host string
+
+	// Count of workers which send requests, RATE_LIMIT in configuration of agent.
+	// If MaxRequestsPerMoment is empty that will be use default value - 5.
 	MaxRequestsPerMoment int
 
+	// Size of queue with requests for workers. Report waits while queue is full.
+	// If QueueSize is empty that will be use default value - 100.
+	QueueSize int
+
+	// Limit of requests per second for all workers and max count of requests
+	// which could be sent at once after idle. If RequestsPerSecond is empty rate is not limited.
+	// If Burst is empty that will be use default value - 1.
+	RequestsPerSecond float64
+	Burst             int
+
 	Timeout      time.Duration // Time in seconds
 	MaxIdleConns int           // Max cached connections
+
+	// DisableCompression disables gzip for request and response bodies
+	DisableCompression bool
+
+	// Key for HMAC-SHA256 signing. If Key is empty signing is disabled
+	Key string
+
+	// Path to PEM file with public key of server for encryption of
+	// request bodies. If CryptoKey is empty encryption is disabled
+	CryptoKey string
+
+	// TLS options. If any of them is set agent uses https
+	TLSCA         string // Path to PEM file with CA certificates for verifying server
+	TLSCert       string // Path to PEM file with client certificate for mutual TLS
+	TLSKey        string // Path to PEM file with client key for mutual TLS
+	TLSServerName string // Server name in certificate if it differs from host
+
+	// Bearer token for server API. If TokenFile is set token is read
+	// from file and re-read when file changes
+	Token     string
+	TokenFile string
+
+	// Count of retries for failed requests.
+	// If Retries is empty that will be use default value - 3. Negative value disables retries.
+	// Retries of one report never take longer than ReportInterval
+	Retries              int
+	RetryInitialInterval time.Duration
+	RetryMaxInterval     time.Duration
+
+	// Every ResyncInterval all metrics are reported, between that only changed metrics are reported.
+	// If ResyncInterval is empty that will be use default value - 5 minutes. Negative value disables change tracking.
+	ResyncInterval time.Duration
+
+	// Names of metrics after relabeling which are reported every time even if they are not changed
+	AlwaysReport []string
+
+	// Names of gauges which are sampled after every collect of their collector and reported with min, max,
+	// avg and count of samples since last report to destination as gauges <name>_min, <name>_max, <name>_avg, <name>_samples.
+	// Gauges which are not updated by collectors are sampled only at report.
+	// Name could be pattern, e.g. "go_memstats_*", see path.Match. Aggregated series are
+	// relabeled as other metrics. If push is disabled every scrape starts the next window.
+	// If Aggregate is empty aggregation is disabled
+	Aggregate []string
+
+	// Rules for names of metrics and static labels. They are applied in push and pull modes.
+	// Server keys metrics only by name, so in push mode static labels are added to names
+	// like "Alloc;env=prod;host=a", see relabel.Relabeler.Series.
+	// If Relabel is nil names are not changed
+	Relabel *relabel.Config
+
+	// Max duration of final report on shutdown.
+	// If ShutdownTimeout is empty that will be use default value - 5 seconds.
+	ShutdownTimeout time.Duration
+
+	// Directory for reports which were not delivered while server is unavailable.
+	// If SpoolDir is empty reports are not stored on disk. It is not used by Destinations,
+	// every destination sets its own directory
+	SpoolDir string
+	// Max size of stored reports in bytes. The oldest reports are dropped when it is reached.
+	// If SpoolMaxSize is empty that will be use default value - 64 MiB.
+	SpoolMaxSize int64
+	// Stored reports older than SpoolMaxAge are dropped. If SpoolMaxAge is empty age is not limited
+	SpoolMaxAge time.Duration
+
+	// Address for pull mode, metrics are served on /metrics and /metrics/json.
+	// If ListenAddress is empty pull mode is disabled
+	ListenAddress string
+
+	// DisablePush disables reports to server, metrics are available in pull mode only
+	DisablePush bool
+
+	// DryRun prints requests to DryRunOutput instead of sending them, every request succeeds.
+	// Spool is not used in dry run. DryRunFormat is DryRunText or DryRunJSON.
+	// If DryRunFormat is empty that will be use default value - DryRunText.
+	// If DryRunOutput is empty that will be use os.Stdout
+	DryRun       bool
+	DryRunFormat string
+	DryRunOutput io.Writer
 }
 
 // New constructor for Agent
@@ -144,15 +291,229 @@ func New(c *Config) (*Agent, error) {
 		c.MaxRequestsPerMoment = defaultMaxRequestsPerMoment
 	}
 
-	return &Agent{
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaultQueueSize
+	}
+
+	if c.ResyncInterval == 0 {
+		c.ResyncInterval = defaultResyncInterval
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	if c.Retries == 0 {
+		c.Retries = defaultRetries
+	}
+
+	if c.DisablePush && c.ListenAddress == "" {
+		return nil, errors.New("push is disabled, but listen address for pull mode is not set")
+	}
+
+	var relabeler *relabel.Relabeler
+
+	if c.Relabel != nil {
+		var err error
+
+		if relabeler, err = relabel.New(c.Relabel); err != nil {
+			return nil, fmt.Errorf("invalid relabel config: %w", err)
+		}
+	}
+
+	var printer *dryRunPrinter
+
+	if c.DryRun {
+		out := c.DryRunOutput
+		if out == nil {
+			out = os.Stdout
+		}
+
+		var err error
+
+		if printer, err = newDryRunPrinter(out, c.DryRunFormat); err != nil {
+			return nil, err
+		}
+	}
+
+	dests, err := c.destinations(printer)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []*route
+
+	if c.Failover {
+		routes = append(routes, newRoute(dests, newChanges(c.ResyncInterval, c.AlwaysReport), relabeler))
+	} else {
+		for _, d := range dests {
+			routes = append(routes, newRoute([]*destination{d}, newChanges(c.ResyncInterval, c.AlwaysReport), relabeler))
+		}
+	}
+
+	var aggr *aggregator
+
+	if len(c.Aggregate) > 0 {
+		if aggr, err = newAggregator(c.Aggregate, len(routes)); err != nil {
+			closeDestinations(dests)
+
+			return nil, fmt.Errorf("invalid aggregate config: %w", err)
+		}
+	}
+
+	a := &Agent{
 		container:      NewTracker(),
-		client:         NewClient(c.Timeout, c.MaxIdleConns),
 		reportInterval: c.ReportInterval,
 		pollInterval:   c.PollInterval,
 
-		host:                 c.Host,
-		maxRequestsPerMoment: c.MaxRequestsPerMoment,
-	}, nil
+		exit:            make(chan struct{}),
+		shutdownTimeout: c.ShutdownTimeout,
+
+		listenAddress: c.ListenAddress,
+		disablePush:   c.DisablePush,
+		relabeler:     relabeler,
+		collisions:    make(map[string]struct{}),
+		aggregator:    aggr,
+
+		dests:  dests,
+		routes: routes,
+	}
+
+	// Registry tracks metrics through agent, so CustomTracker changes container for collectors too
+	a.collectors = NewRegistry(a, c.PollInterval)
+
+	if aggr != nil {
+		a.collectors.observe = aggr.Observe
+	}
+
+	return a, nil
+}
+
+// destinations creating destinations from Destinations with options of Config as default values.
+// Destinations which were created are closed if any of them could not be created
+func (c *Config) destinations(printer *dryRunPrinter) ([]*destination, error) {
+	list := c.Destinations
+	if len(list) == 0 {
+		list = []Destination{c.defaultDestination()}
+	}
+
+	dests := make([]*destination, 0, len(list))
+	names := make(map[string]struct{}, len(list))
+
+	for i := range list {
+		d := c.inherit(list[i])
+
+		if c.Failover && i > 0 && d.SpoolDir != "" {
+			closeDestinations(dests)
+
+			return nil, fmt.Errorf("destination %q has spool, but in failover mode only the first destination could have it", d.Name)
+		}
+
+		dest, err := newDestination(&d, c.ReportInterval, printer)
+		if err != nil {
+			closeDestinations(dests)
+
+			return nil, fmt.Errorf("invalid destination %q: %w", d.Name, err)
+		}
+
+		if _, ok := names[dest.name]; ok {
+			closeDestinations(append(dests, dest))
+
+			return nil, fmt.Errorf("duplicate destination %q", dest.name)
+		}
+
+		names[dest.name] = struct{}{}
+		dests = append(dests, dest)
+	}
+
+	return dests, nil
+}
+
+// inherit filling empty options of destination with options of Config.
+// Key, CryptoKey, Token, TokenFile and TLS options are filled only if InheritCredentials is set
+func (c *Config) inherit(d Destination) Destination {
+	def := c.defaultDestination()
+
+	if d.Client == nil {
+		d.Client = def.Client
+	}
+
+	if d.MaxRequestsPerMoment <= 0 {
+		d.MaxRequestsPerMoment = def.MaxRequestsPerMoment
+	}
+
+	if d.QueueSize <= 0 {
+		d.QueueSize = def.QueueSize
+	}
+
+	if d.RequestsPerSecond <= 0 {
+		d.RequestsPerSecond = def.RequestsPerSecond
+	}
+
+	if d.Burst <= 0 {
+		d.Burst = def.Burst
+	}
+
+	if d.Timeout <= 0 {
+		d.Timeout = def.Timeout
+	}
+
+	if d.MaxIdleConns <= 0 {
+		d.MaxIdleConns = def.MaxIdleConns
+	}
+
+	if !d.DisableCompression {
+		d.DisableCompression = def.DisableCompression
+	}
+
+	// Credentials of one server must not be sent to other servers, so they are inherited only on request
+	if d.InheritCredentials {
+		if d.Key == "" {
+			d.Key = def.Key
+		}
+
+		if d.CryptoKey == "" {
+			d.CryptoKey = def.CryptoKey
+		}
+
+		// TLS options are inherited together, so certificate of one server is not mixed with options of other
+		if d.TLSCA == "" && d.TLSCert == "" && d.TLSKey == "" && d.TLSServerName == "" {
+			d.TLSCA, d.TLSCert, d.TLSKey, d.TLSServerName = def.TLSCA, def.TLSCert, def.TLSKey, def.TLSServerName
+		}
+
+		if d.Token == "" && d.TokenFile == "" {
+			d.Token, d.TokenFile = def.Token, def.TokenFile
+		}
+	}
+
+	if d.Retries == 0 {
+		d.Retries = def.Retries
+	}
+
+	if d.RetryInitialInterval <= 0 {
+		d.RetryInitialInterval = def.RetryInitialInterval
+	}
+
+	if d.RetryMaxInterval <= 0 {
+		d.RetryMaxInterval = def.RetryMaxInterval
+	}
+
+	// Spool directory is never shared, two queues in one directory would corrupt each other
+	if d.SpoolMaxSize <= 0 {
+		d.SpoolMaxSize = def.SpoolMaxSize
+	}
+
+	if d.SpoolMaxAge <= 0 {
+		d.SpoolMaxAge = def.SpoolMaxAge
+	}
+
+	return d
+}
+
+func closeDestinations(dests []*destination) {
+	for _, d := range dests {
+		d.close()
+	}
 }
 
 // Track adding metric to track
@@ -170,78 +531,212 @@ func (a *Agent) Status() []Status {
 	return a.container.Status()
 }
 
+// Register adding collector which is run with Run
+func (a *Agent) Register(col Collector, c *CollectorConfig) error {
+	return a.collectors.Register(col, c)
+}
+
 func (a *Agent) CustomTracker(t Tracker) {
 	a.container = t
 }
 
-// Run call unblocking operation and start event
-// cycle with collect metrics
-// from runtime
-func (a *Agent) Run() {
-	reportTicker := time.NewTicker(a.reportInterval)
-	a.exit = make(chan struct{})
+// Run collecting and reporting metrics until ctx is cancelled or Shutdown is called.
+// On stop report in progress is cancelled and final report is sent within ShutdownTimeout,
+// then all resources of agent are closed, so Run could be called only once.
+// Returns error if final report was neither delivered nor stored in spool
+func (a *Agent) Run(ctx context.Context) error {
+	if err := a.start(); err != nil {
+		return err
+	}
+
+	defer a.running.Done()
+
+	ctx, cancel := a.withExit(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		a.collectors.Run(ctx)
+	}()
+
+	if a.listenAddress != "" {
+		if err := a.serve(ctx, &wg); err != nil {
+			cancel()
+			wg.Wait()
+			a.close()
+
+			return err
+		}
+	}
+
+	// Without push reports ticker is never fired
+	var reports <-chan time.Time
+
+	if !a.disablePush {
+		reportTicker := time.NewTicker(a.reportInterval)
+		defer reportTicker.Stop()
+
+		reports = reportTicker.C
+	}
+
+	// Reports which are in progress, every route runs its own report
+	var inflight sync.WaitGroup
 
 	for {
 		select {
-		case <-a.exit:
-			// Gracefully shutdown ticker
-			reportTicker.Stop()
+		case <-ctx.Done():
+			wg.Wait()
+			inflight.Wait()
+
+			var err error
+			if !a.disablePush {
+				err = a.flush()
+			}
+
+			a.close()
+
 			log.Printf("agent been gracefully shutdown")
 
-			return
-		case <-reportTicker.C:
-			a.report()
+			return err
+		case <-reports:
+			a.dispatch(ctx, &inflight)
 		}
 	}
 }
 
-// Shutdown call functional for correct exits from program
-func (a *Agent) Shutdown() {
-	// Gracefully shutdown all agent's components
-	a.onceCloser.Do(func() {
-		close(a.exit)
-		a.client.Shutdown()
-	})
+// Once running every collector one time and sending one report, then all resources
+// of agent are closed. It is for running agent from cron or in smoke tests.
+// Returns error if report was not delivered, including report which was stored in spool
+func (a *Agent) Once(ctx context.Context) error {
+	if a.disablePush {
+		return errors.New("push is disabled")
+	}
+
+	if err := a.start(); err != nil {
+		return err
+	}
+
+	defer a.running.Done()
+	defer a.close()
+
+	ctx, cancel := a.withExit(ctx)
+	defer cancel()
+
+	a.collectors.CollectOnce(ctx)
+
+	if err := a.report(ctx); err != nil {
+		return err
+	}
+
+	for _, r := range a.routes {
+		if r.stored() {
+			return fmt.Errorf("%s is unavailable, report is stored in spool", r.name())
+		}
+	}
+
+	return nil
 }
 
-// Sending report with metrics
-func (a *Agent) report() {
-	s := a.container.Status()
+// start marking agent as running. Agent could be run only once
+func (a *Agent) start() error {
+	a.runMu.Lock()
+	defer a.runMu.Unlock()
 
-	// For implementation semaphore
-	channel := make(chan struct{}, a.maxRequestsPerMoment)
+	select {
+	case <-a.exit:
+		return errors.New("agent is stopped")
+	default:
+	}
 
-	var wg sync.WaitGroup
+	if a.started {
+		return errors.New("agent is already running")
+	}
 
-	for i := 0; i < len(s); i++ {
+	a.started = true
+	a.running.Add(1)
 
-		wg.Add(1)
-		go func(x int) {
-			defer wg.Done()
+	return nil
+}
 
-			channel <- struct{}{}
+// withExit returning context which is cancelled on Shutdown
+func (a *Agent) withExit(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
 
-			url := fmt.Sprintf("http://%s/update/%s/%s/%s", a.host, s[x].MetricType, s[x].Name, s[x].Value)
+	go func() {
+		select {
+		case <-a.exit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
-			log.Printf("start of request to url: %s\n", url)
+	return ctx, cancel
+}
 
-			err := a.client.DoRequest(http.MethodPost, url, map[string]string{contentTypeHeader: defaultContentType}, nil)
+// flush sending final report with values which were collected after last report
+func (a *Agent) flush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
 
-			if err != nil {
-				log.Printf("request ended with error: %s\n", err)
-			} else {
-				log.Printf("request ended without error")
-			}
+	if err := a.report(ctx); err != nil {
+		return fmt.Errorf("final report failed: %w", err)
+	}
+
+	return nil
+}
+
+// Shutdown stopping Run and waiting for final report. It could be called before Run
+func (a *Agent) Shutdown() {
+	a.runMu.Lock()
+	a.onceExit.Do(func() {
+		close(a.exit)
+	})
+	a.runMu.Unlock()
+
+	a.running.Wait()
+	a.close()
+}
+
+// close closing all agent's components. Workers drain their queue before
+func (a *Agent) close() {
+	a.onceCloser.Do(func() {
+		closeDestinations(a.dests)
+	})
+}
+
+// outboundIP returning local address which is used for connections to host.
+// UDP dial doesn't send any packets, it only selects route
+func outboundIP(host string) string {
+	conn, err := net.Dial("udp", host)
+	if err != nil {
+		log.Printf("unable to detect outbound address: %s\n", err)
 
-			<-channel
-		}(i)
+		return ""
 	}
 
-	wg.Wait()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return ""
+	}
+
+	return addr.IP.String()
 }
 
 func getMetricType(met Metric) string {
 	switch met.(type) {
+	case *gaugeFunc:
+		return gaugeType
+	case *counterFunc:
+		return counterType
 	case Gauge:
 		return gaugeType
 	case Counter:
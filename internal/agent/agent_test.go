@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mtrrun/internal/model"
+	"github.com/mtrrun/internal/sign"
+)
+
+func TestAgentFinalReport(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []model.Metrics
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m model.Metrics
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&m))
+
+		mu.Lock()
+		received = append(received, m)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	a, err := New(&Config{
+		Host:               strings.TrimPrefix(srv.URL, "http://"),
+		ReportInterval:     time.Hour,
+		PollInterval:       time.Hour,
+		DisableCompression: true,
+	})
+	require.NoError(t, err)
+
+	c := NewCounter("Requests", "")
+	c.Add(5)
+	a.Track(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Report interval is not reached, so metrics are delivered with final report
+	require.NoError(t, a.Run(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Len(t, received, 1)
+	require.Equal(t, "Requests", received[0].ID)
+	require.Equal(t, int64(5), *received[0].Delta)
+
+	// Agent could not be run again
+	require.Error(t, a.Run(context.Background()))
+}
+
+func TestAgentShutdownBeforeRun(t *testing.T) {
+	a, err := New(&Config{Host: "127.0.0.1:1"})
+	require.NoError(t, err)
+
+	a.Shutdown()
+	a.Shutdown()
+
+	require.Error(t, a.Run(context.Background()))
+}
+
+func TestAgentOnce(t *testing.T) {
+	var status int32 = http.StatusOK
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer srv.Close()
+
+	var collected int32
+
+	newAgent := func() *Agent {
+		a, err := New(&Config{
+			Host:               strings.TrimPrefix(srv.URL, "http://"),
+			DisableCompression: true,
+		})
+		require.NoError(t, err)
+
+		c := NewCounter("Collected", "")
+
+		col := &testCollector{name: "test", collect: func(ctx context.Context, t Tracker) error {
+			atomic.AddInt32(&collected, 1)
+			c.Inc()
+			t.Track(c)
+
+			return nil
+		}}
+		require.NoError(t, a.Register(col, &CollectorConfig{Interval: time.Hour}))
+
+		return a
+	}
+
+	a := newAgent()
+	require.NoError(t, a.Once(context.Background()))
+	require.Equal(t, int32(1), atomic.LoadInt32(&collected))
+
+	// Agent is closed after one report
+	require.Error(t, a.Once(context.Background()))
+
+	atomic.StoreInt32(&status, http.StatusInternalServerError)
+	require.Error(t, newAgent().Once(context.Background()))
+}
+
+func TestAgentDryRun(t *testing.T) {
+	var out bytes.Buffer
+
+	a, err := New(&Config{
+		Host:         "127.0.0.1:1",
+		Key:          "secret",
+		Token:        "token",
+		DryRun:       true,
+		DryRunFormat: DryRunJSON,
+		DryRunOutput: &out,
+	})
+	require.NoError(t, err)
+
+	g := NewGauge("Alloc", "")
+	g.Set(1.5)
+	a.Track(g)
+
+	// Nothing listens on address, so report succeeds only without requests
+	require.NoError(t, a.Once(context.Background()))
+
+	var r dryRunRequest
+	require.NoError(t, json.Unmarshal(out.Bytes(), &r))
+
+	require.Equal(t, http.MethodPost, r.Method)
+	require.Equal(t, "http://127.0.0.1:1/update/", r.URL)
+	require.Equal(t, `{"id":"Alloc","type":"gauge","value":1.5}`, r.Body)
+	require.Equal(t, encodingGzip, r.Header[contentEncodingHeader])
+	require.Equal(t, sign.Sum([]byte("secret"), []byte(r.Body)), r.Header[http.CanonicalHeaderKey(sign.Header)])
+	require.Equal(t, redactedToken, r.Header[authorizationHeader])
+}
@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sync"
+)
+
+// Series of aggregated gauge which are reported with gauge itself, i.e. last value.
+// For gauge HeapAlloc they are HeapAlloc_min, HeapAlloc_max, HeapAlloc_avg and HeapAlloc_samples
+const (
+	aggregateMinSuffix     = "_min"
+	aggregateMaxSuffix     = "_max"
+	aggregateAvgSuffix     = "_avg"
+	aggregateSamplesSuffix = "_samples"
+)
+
+// aggregator accumulating samples of gauges between reports,
+// so spikes between reports are not lost. Every route has its own window,
+// so route which skipped report gets samples of skipped interval with next report
+type aggregator struct {
+	mu sync.Mutex
+
+	// Patterns of names of aggregated gauges, see path.Match
+	patterns []string
+
+	// Samples of gauges since last report of every route. Key is name of gauge
+	windows []map[string]*window
+
+	// Result of matching names with patterns
+	matched map[string]bool
+}
+
+// window is summary of gauge samples in one report interval
+type window struct {
+	min   float64
+	max   float64
+	sum   float64
+	count int64
+}
+
+// newAggregator constructor for aggregator with number of windows, one for every route
+func newAggregator(patterns []string, windows int) (*aggregator, error) {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+
+	g := &aggregator{
+		patterns: patterns,
+		windows:  make([]map[string]*window, windows),
+		matched:  make(map[string]bool),
+	}
+
+	for i := range g.windows {
+		g.windows[i] = make(map[string]*window)
+	}
+
+	return g, nil
+}
+
+// Observe adding current values of aggregated gauges to their windows of every route. Not finite values are skipped
+func (g *aggregator) Observe(s []Status) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, st := range s {
+		if st.MetricType != gaugeType || !st.Value.IsFinite() || !g.match(st.Name) {
+			continue
+		}
+
+		v := st.Value.Float64()
+
+		for _, windows := range g.windows {
+			w, ok := windows[st.Name]
+			if !ok {
+				windows[st.Name] = &window{min: v, max: v, sum: v, count: 1}
+
+				continue
+			}
+
+			w.min = math.Min(w.min, v)
+			w.max = math.Max(w.max, v)
+			w.sum += v
+			w.count++
+		}
+	}
+}
+
+// Append adding min, max, avg and count of samples of aggregated gauges in window of route to statuses.
+// Gauge without samples is aggregated from its current value.
+// If reset is true the next window of route is started. Nil aggregator returns statuses as is
+func (g *aggregator) Append(s []Status, route int, reset bool) []Status {
+	if g == nil {
+		return s
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	result := make([]Status, len(s), len(s)*2)
+	copy(result, s)
+
+	for _, st := range s {
+		if st.MetricType != gaugeType || !g.match(st.Name) {
+			continue
+		}
+
+		w, ok := g.windows[route][st.Name]
+		if !ok {
+			if !st.Value.IsFinite() {
+				continue
+			}
+
+			v := st.Value.Float64()
+			w = &window{min: v, max: v, sum: v, count: 1}
+		}
+
+		result = append(result,
+			Status{Name: st.Name + aggregateMinSuffix, MetricType: gaugeType, Value: Float64Value(w.min), Help: st.Help},
+			Status{Name: st.Name + aggregateMaxSuffix, MetricType: gaugeType, Value: Float64Value(w.max), Help: st.Help},
+			Status{Name: st.Name + aggregateAvgSuffix, MetricType: gaugeType, Value: Float64Value(w.sum / float64(w.count)), Help: st.Help},
+			Status{Name: st.Name + aggregateSamplesSuffix, MetricType: gaugeType, Value: Int64Value(w.count), Help: st.Help},
+		)
+	}
+
+	if reset {
+		g.windows[route] = make(map[string]*window, len(g.windows[route]))
+	}
+
+	return result
+}
+
+// match reporting whether gauge is aggregated. Result is cached, so patterns are matched once per name
+func (g *aggregator) match(name string) bool {
+	if ok, cached := g.matched[name]; cached {
+		return ok
+	}
+
+	ok := false
+
+	for _, p := range g.patterns {
+		if matched, _ := path.Match(p, name); matched {
+			ok = true
+
+			break
+		}
+	}
+
+	g.matched[name] = ok
+
+	return ok
+}
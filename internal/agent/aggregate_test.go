@@ -0,0 +1,246 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func findStatus(s []Status, name string) (Status, bool) {
+	for _, st := range s {
+		if st.Name == name {
+			return st, true
+		}
+	}
+
+	return Status{}, false
+}
+
+func TestAggregator(t *testing.T) {
+	g, err := newAggregator([]string{"Heap*"}, 1)
+	require.NoError(t, err)
+
+	for _, v := range []float64{5, 1, math.NaN(), 9, 3} {
+		g.Observe([]Status{
+			{Name: "HeapAlloc", MetricType: gaugeType, Value: Float64Value(v)},
+			{Name: "Load", MetricType: gaugeType, Value: Float64Value(v)},
+			{Name: "HeapCount", MetricType: counterType, Value: Int64Value(1)},
+		})
+	}
+
+	current := []Status{
+		{Name: "HeapAlloc", MetricType: gaugeType, Value: Float64Value(3)},
+		{Name: "Load", MetricType: gaugeType, Value: Float64Value(3)},
+		{Name: "HeapCount", MetricType: counterType, Value: Int64Value(5)},
+	}
+
+	s := g.Append(current, 0, true)
+	require.Len(t, s, 7)
+
+	expected := map[string]float64{
+		"HeapAlloc_min":     1,
+		"HeapAlloc_max":     9,
+		"HeapAlloc_avg":     4.5,
+		"HeapAlloc_samples": 4,
+	}
+
+	for name, v := range expected {
+		st, ok := findStatus(s, name)
+		require.True(t, ok, name)
+		require.Equal(t, gaugeType, st.MetricType)
+		require.Equal(t, v, st.Value.Float64(), name)
+	}
+
+	_, ok := findStatus(s, "Load_min")
+	require.False(t, ok)
+
+	_, ok = findStatus(s, "HeapCount_min")
+	require.False(t, ok)
+
+	// Window without samples is aggregated from current value
+	s = g.Append(current, 0, true)
+
+	st, ok := findStatus(s, "HeapAlloc_max")
+	require.True(t, ok)
+	require.Equal(t, 3.0, st.Value.Float64())
+
+	st, ok = findStatus(s, "HeapAlloc_samples")
+	require.True(t, ok)
+	require.Equal(t, 1.0, st.Value.Float64())
+
+	_, err = newAggregator([]string{"Heap["}, 1)
+	require.Error(t, err)
+}
+
+func TestAggregatorRouteWindows(t *testing.T) {
+	g, err := newAggregator([]string{"Load"}, 2)
+	require.NoError(t, err)
+
+	for _, v := range []float64{5, 100, 1} {
+		g.Observe([]Status{{Name: "Load", MetricType: gaugeType, Value: Float64Value(v)}})
+	}
+
+	current := []Status{{Name: "Load", MetricType: gaugeType, Value: Float64Value(1)}}
+
+	// Report of the first route doesn't reset window of the second one
+	st, ok := findStatus(g.Append(current, 0, true), "Load_max")
+	require.True(t, ok)
+	require.Equal(t, 100.0, st.Value.Float64())
+
+	g.Observe(current)
+
+	st, ok = findStatus(g.Append(current, 0, true), "Load_max")
+	require.True(t, ok)
+	require.Equal(t, 1.0, st.Value.Float64())
+
+	s := g.Append(current, 1, true)
+
+	st, ok = findStatus(s, "Load_max")
+	require.True(t, ok)
+	require.Equal(t, 100.0, st.Value.Float64())
+
+	st, ok = findStatus(s, "Load_samples")
+	require.True(t, ok)
+	require.Equal(t, 4.0, st.Value.Float64())
+}
+
+func TestDispatchBusyRouteKeepsWindow(t *testing.T) {
+	fast := newRecorder(t, http.StatusOK)
+	slow := newRecorder(t, http.StatusOK)
+
+	a, err := New(&Config{
+		PollInterval:       time.Hour,
+		DisableCompression: true,
+		Aggregate:          []string{"Load"},
+		Destinations: []Destination{
+			{Name: "fast", Host: fast.host()},
+			{Name: "slow", Host: slow.host()},
+		},
+	})
+	require.NoError(t, err)
+
+	g := NewGauge("Load", "")
+	a.Track(g)
+
+	// Spike happens while report to slow destination is still running
+	for _, v := range []float64{5, 100, 1} {
+		g.Set(v)
+		a.aggregator.Observe(a.container.Status())
+	}
+
+	require.True(t, a.routes[1].tryLock())
+
+	var wg sync.WaitGroup
+
+	a.dispatch(context.Background(), &wg)
+	wg.Wait()
+
+	a.routes[1].unlock()
+
+	a.dispatch(context.Background(), &wg)
+	wg.Wait()
+
+	maxima := func(rec *recorder) []float64 {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+
+		var result []float64
+
+		for _, m := range rec.received {
+			if m.ID == "Load_max" {
+				result = append(result, *m.Value)
+			}
+		}
+
+		return result
+	}
+
+	// The second report to fast destination has new window
+	require.Equal(t, []float64{100, 1}, maxima(fast))
+	require.Equal(t, []float64{100}, maxima(slow))
+}
+
+func TestAgentAggregate(t *testing.T) {
+	rec := newRecorder(t, http.StatusOK)
+
+	a, err := New(&Config{
+		Host:               rec.host(),
+		ReportInterval:     time.Hour,
+		PollInterval:       time.Hour,
+		DisableCompression: true,
+		Aggregate:          []string{"Load"},
+	})
+	require.NoError(t, err)
+
+	g := NewGauge("Load", "")
+	values := []float64{5, 100, 1}
+	collects := 0
+
+	// Spike lasts only one collect
+	require.NoError(t, a.Register(&testCollector{
+		name: "load",
+		collect: func(ctx context.Context, tr Tracker) error {
+			if collects == 0 {
+				tr.Track(g)
+			}
+
+			g.Set(values[collects])
+
+			if collects < len(values)-1 {
+				collects++
+			}
+
+			return nil
+		},
+	}, &CollectorConfig{Interval: 5 * time.Millisecond}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	received := make(map[string]float64)
+
+	for _, m := range rec.received {
+		if m.Value != nil {
+			received[m.ID] = *m.Value
+		}
+	}
+
+	require.Equal(t, 1.0, received["Load"])
+	require.Equal(t, 100.0, received["Load_max"])
+	require.Equal(t, 1.0, received["Load_min"])
+	require.GreaterOrEqual(t, received["Load_samples"], 3.0)
+}
+
+func TestCollectorTracker(t *testing.T) {
+	parent := NewTracker()
+	tr := newCollectorTracker(parent)
+
+	g := NewGauge("Load", "")
+	tr.Track(g)
+	parent.Track(NewGauge("Other", ""))
+
+	// Collector tracker has only metrics of collector
+	require.Len(t, tr.Status(), 1)
+	require.Len(t, parent.Status(), 2)
+
+	tr.Untrack(g)
+	require.Empty(t, tr.Status())
+	require.Len(t, parent.Status(), 1)
+}
@@ -2,57 +2,245 @@ package agent
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"time"
+
+	"github.com/mtrrun/internal/envelope"
+	"github.com/mtrrun/internal/sign"
+)
+
+const (
+	contentEncodingHeader = "Content-Encoding"
+	acceptEncodingHeader  = "Accept-Encoding"
+	encodingGzip          = "gzip"
+	realIPHeader          = "X-Real-IP"
+	authorizationHeader   = "Authorization"
+	retryAfterHeader      = "Retry-After"
 )
 
+var errInvalidSignature = errors.New("response signature is invalid")
+
 // customHttpError for check results if request contains status 4** or 5**
 type customHTTPError struct {
 	Message string
 	Status  int
+
+	// RetryAfter is delay from header "Retry-After". Zero if header is absent
+	RetryAfter time.Duration
 }
 
 func (e *customHTTPError) Error() string {
 	return fmt.Sprintf("request ended with status %d and error: %s", e.Status, e.Message)
 }
 
+// Retriable reports whether request could succeed if it is repeated later.
+// Validation errors with status 4** fail fast
+func (e *customHTTPError) Retriable() bool {
+	switch e.Status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
 // Client http implementation
 type client struct {
 	http.Client
+
+	compress  bool
+	key       []byte
+	encrypter *envelope.Encrypter
+	realIP    string
+	token     TokenSource
+	retry     RetryPolicy
+}
+
+// ClientConfig configuration list for Client
+type ClientConfig struct {
+	Timeout      time.Duration // Time in seconds
+	MaxIdleConns int           // Max cached connections
+
+	// DisableCompression disables gzip compression of request bodies
+	// and asking server for compressed responses
+	DisableCompression bool
+
+	// Key for signing requests and verifying responses with HMAC-SHA256.
+	// If Key is empty requests are not signed
+	Key string
+
+	// Encrypter for request bodies. If Encrypter is nil bodies are sent as is
+	Encrypter *envelope.Encrypter
+
+	// TLSConfig for https connections. If TLSConfig is nil default configuration is used
+	TLSConfig *tls.Config
+
+	// RealIP is outbound address of agent sent in header "X-Real-IP"
+	RealIP string
+
+	// Token for header "Authorization". If Token is nil header is not sent
+	Token TokenSource
+
+	// Retry policy for failed requests
+	Retry RetryPolicy
+
+	// Transport for requests. If Transport is nil that will be use
+	// http.Transport with TLSConfig and MaxIdleConns
+	Transport http.RoundTripper
 }
 
 // NewClient constructor for client
-func NewClient(timeout time.Duration, maxIdleConns int) Client {
-	transport := &http.Transport{}
+func NewClient(c *ClientConfig) Client {
+	transport := c.Transport
+
+	if transport == nil {
+		t := &http.Transport{
+			TLSClientConfig: c.TLSConfig,
+		}
 
-	if maxIdleConns != 0 {
-		transport.MaxIdleConns = maxIdleConns
+		if c.MaxIdleConns != 0 {
+			t.MaxIdleConns = c.MaxIdleConns
+		}
+
+		transport = t
 	}
 
-	newClient := &client{}
+	newClient := &client{
+		compress:  !c.DisableCompression,
+		key:       []byte(c.Key),
+		encrypter: c.Encrypter,
+		realIP:    c.RealIP,
+		token:     c.Token,
+		retry:     c.Retry,
+	}
 	newClient.Transport = transport
 
-	if timeout != 0 {
-		newClient.Timeout = timeout
+	if c.Timeout != 0 {
+		newClient.Timeout = c.Timeout
 	}
 
 	return newClient
 
 }
 
-// DoRequest sending request to resource
-func (c *client) DoRequest(method, url string, headers map[string]string, body []byte) error {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+// DoRequest sending request to resource. Request and waiting between retries are cancelled with ctx
+func (c *client) DoRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) error {
+	var sum string
+
+	if len(c.key) > 0 {
+		data := body
+		if len(data) == 0 {
+			data = []byte(requestURI(url))
+		}
+
+		sum = sign.Sum(c.key, data)
+	}
+
+	if c.compress && len(body) > 0 {
+		compressed, err := compressGzip(body)
+		if err != nil {
+			return err
+		}
+
+		body = compressed
+	}
+
+	encrypted := false
+
+	if c.encrypter != nil && len(body) > 0 {
+		ciphertext, err := c.encrypter.Encrypt(body)
+		if err != nil {
+			return err
+		}
+
+		body = ciphertext
+		encrypted = true
+	}
+
+	header := make(http.Header, len(headers)+6)
 
 	for k, v := range headers {
-		req.Header.Add(k, v)
+		header.Add(k, v)
 	}
+
+	if sum != "" {
+		header.Set(sign.Header, sum)
+	}
+
+	if c.realIP != "" {
+		header.Set(realIPHeader, c.realIP)
+	}
+
+	if encrypted {
+		header.Set(envelope.Header, envelope.Scheme)
+		header.Set(envelope.KeyIDHeader, c.encrypter.KeyID())
+	}
+
+	if c.compress {
+		// Setting header manually turns off transparent
+		// decompression in transport, so response decompressed below
+		header.Set(acceptEncodingHeader, encodingGzip)
+
+		if len(body) > 0 {
+			header.Set(contentEncodingHeader, encodingGzip)
+		}
+	}
+
+	start := time.Now()
+
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, url, header, body)
+		if err == nil || ctx.Err() != nil || !isRetriable(err) || attempt >= c.retry.MaxRetries {
+			return err
+		}
+
+		wait, ok := c.retry.next(attempt, err, time.Since(start))
+		if !ok {
+			return err
+		}
+
+		log.Printf("request to %s failed: %s. Retry in %s\n", url, err, wait)
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// send making one attempt of request
+func (c *client) send(ctx context.Context, method, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 
+	req.Header = header.Clone()
+
+	if c.token != nil {
+		token, err := c.token.Token()
+		if err != nil {
+			return fmt.Errorf("unable to get token: %w", err)
+		}
+
+		if token != "" {
+			req.Header.Set(authorizationHeader, "Bearer "+token)
+		}
+	}
+
 	resp, err := c.Do(req)
 	if err != nil {
 		return err
@@ -62,20 +250,23 @@ func (c *client) DoRequest(method, url string, headers map[string]string, body [
 		_ = resp.Body.Close()
 	}()
 
-	if resp.StatusCode >= http.StatusBadRequest {
-		var b []byte
-
-		b, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
+	b, err := readBody(resp)
+	if err != nil {
+		return err
+	}
 
+	if resp.StatusCode >= http.StatusBadRequest {
 		return &customHTTPError{
-			Message: string(b),
-			Status:  resp.StatusCode,
+			Message:    string(b),
+			Status:     resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get(retryAfterHeader)),
 		}
 	}
 
+	if len(c.key) > 0 && !sign.Verify(c.key, b, resp.Header.Get(sign.Header)) {
+		return errInvalidSignature
+	}
+
 	return nil
 }
 
@@ -83,3 +274,48 @@ func (c *client) DoRequest(method, url string, headers map[string]string, body [
 func (c *client) Shutdown() {
 	c.CloseIdleConnections()
 }
+
+// requestURI returning path with query from url for signing requests without body
+func requestURI(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return u.RequestURI()
+}
+
+// compressGzip returning body compressed with gzip
+func compressGzip(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readBody reading response body and decompressing it if server sent it with gzip
+func readBody(resp *http.Response) ([]byte, error) {
+	if resp.Header.Get(contentEncodingHeader) != encodingGzip {
+		return io.ReadAll(resp.Body)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = gz.Close()
+	}()
+
+	return io.ReadAll(gz)
+}
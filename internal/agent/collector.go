@@ -0,0 +1,300 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Prefixes of self-metrics of collectors, name of collector is added after "_"
+	metricCollectorErrors   = "CollectorErrors"
+	metricCollectorDuration = "CollectorDuration"
+)
+
+// Collector is interface for source of metrics.
+// Collector creates its metrics and adds them to tracker in Collect
+type Collector interface {
+	// Name of collector for configuration and self-metrics
+	Name() string
+
+	// Describe returning descriptions of metrics which are known before collecting.
+	// Metrics which depend on host, e.g. per-core metrics, could be missing
+	Describe() []Description
+
+	// Collect updating values of metrics and tracking them in t.
+	// ctx is cancelled when timeout of collector is expired or agent is stopped
+	Collect(ctx context.Context, t Tracker) error
+}
+
+// CollectorConfig configuration of collector in Registry
+type CollectorConfig struct {
+	// If Interval is empty that will be use poll interval of agent
+	Interval time.Duration
+
+	// Max duration of one collect. If Timeout is empty that will be use Interval
+	Timeout time.Duration
+}
+
+type registeredCollector struct {
+	collector Collector
+	interval  time.Duration
+	timeout   time.Duration
+
+	// Tracker which remembers metrics of collector
+	tracker *collectorTracker
+
+	errors   Counter
+	duration Gauge
+
+	// Previous collect which was timed out is still running
+	mu      sync.Mutex
+	running bool
+}
+
+// Registry running collectors, every collector on its own interval.
+// Errors, panics and timeouts of collectors don't affect other collectors,
+// they are counted in self-metrics CollectorErrors_<name>
+type Registry struct {
+	mu sync.Mutex
+
+	tracker         Tracker
+	defaultInterval time.Duration
+
+	collectors []*registeredCollector
+	names      map[string]string // name of metric -> name of collector
+
+	// Called with statuses of metrics of collector after every successful collect. Could be nil
+	observe func([]Status)
+}
+
+// NewRegistry constructor for Registry
+func NewRegistry(t Tracker, defaultInterval time.Duration) *Registry {
+	return &Registry{
+		tracker:         t,
+		defaultInterval: defaultInterval,
+		names:           make(map[string]string),
+	}
+}
+
+// Register adding collector. Names of collectors and their metrics must be unique
+func (r *Registry) Register(col Collector, c *CollectorConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := col.Name()
+
+	for _, rc := range r.collectors {
+		if rc.collector.Name() == name {
+			return fmt.Errorf("collector %s is already registered", name)
+		}
+	}
+
+	for _, d := range col.Describe() {
+		if owner, ok := r.names[d.Name]; ok {
+			return fmt.Errorf("metric %s of collector %s is already collected by %s", d.Name, name, owner)
+		}
+	}
+
+	for _, d := range col.Describe() {
+		r.names[d.Name] = name
+	}
+
+	rc := &registeredCollector{
+		collector: col,
+		tracker:   newCollectorTracker(r.tracker),
+		interval:  c.Interval,
+		timeout:   c.Timeout,
+		errors:    NewCounter(metricCollectorErrors+"_"+name, "count of failed collects"),
+		duration:  NewGauge(metricCollectorDuration+"_"+name, "duration of last collect in seconds"),
+	}
+
+	if rc.interval <= 0 {
+		rc.interval = r.defaultInterval
+	}
+
+	if rc.timeout <= 0 {
+		rc.timeout = rc.interval
+	}
+
+	r.tracker.Track(rc.errors)
+	r.tracker.Track(rc.duration)
+
+	r.collectors = append(r.collectors, rc)
+
+	return nil
+}
+
+// Run collecting metrics until ctx is cancelled. It is blocking operation
+func (r *Registry) Run(ctx context.Context) {
+	r.mu.Lock()
+	collectors := make([]*registeredCollector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+
+	for _, rc := range collectors {
+		wg.Add(1)
+
+		go func(rc *registeredCollector) {
+			defer wg.Done()
+
+			ticker := time.NewTicker(rc.interval)
+			defer ticker.Stop()
+
+			r.collect(ctx, rc)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					r.collect(ctx, rc)
+				}
+			}
+		}(rc)
+	}
+
+	wg.Wait()
+
+	log.Println("collectors stopped")
+}
+
+// CollectOnce running every collector one time and waiting for all of them
+func (r *Registry) CollectOnce(ctx context.Context) {
+	r.mu.Lock()
+	collectors := make([]*registeredCollector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+
+	for _, rc := range collectors {
+		wg.Add(1)
+
+		go func(rc *registeredCollector) {
+			defer wg.Done()
+
+			r.collect(ctx, rc)
+		}(rc)
+	}
+
+	wg.Wait()
+}
+
+// collect calling collector with timeout. Collect which was timed out
+// continues in background and next collects are skipped until it ends
+func (r *Registry) collect(ctx context.Context, rc *registeredCollector) {
+	rc.mu.Lock()
+	if rc.running {
+		rc.mu.Unlock()
+		log.Printf("previous collect of %s is still running, skipping\n", rc.collector.Name())
+		rc.errors.Inc()
+
+		return
+	}
+	rc.running = true
+	rc.mu.Unlock()
+
+	start := time.Now()
+	done := make(chan error, 1)
+
+	ctx, cancel := context.WithTimeout(ctx, rc.timeout)
+	defer cancel()
+
+	go func() {
+		defer func() {
+			rc.mu.Lock()
+			rc.running = false
+			rc.mu.Unlock()
+		}()
+
+		done <- safeCollect(ctx, rc.collector, rc.tracker)
+	}()
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("cancelled after %s: %w", time.Since(start), ctx.Err())
+	}
+
+	rc.duration.Set(time.Since(start).Seconds())
+
+	if err != nil {
+		log.Printf("collector %s failed: %s\n", rc.collector.Name(), err)
+		rc.errors.Inc()
+
+		return
+	}
+
+	if r.observe != nil {
+		r.observe(rc.tracker.Status())
+	}
+}
+
+// safeCollect calling collector and returning panic as error
+func safeCollect(ctx context.Context, col Collector, t Tracker) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	return col.Collect(ctx, t)
+}
+
+// collectorTracker tracking metrics in tracker of registry and remembering
+// metrics of one collector, so they could be read after its collect
+type collectorTracker struct {
+	parent Tracker
+
+	mu      sync.Mutex
+	metrics map[string]Metric
+}
+
+func newCollectorTracker(parent Tracker) *collectorTracker {
+	return &collectorTracker{
+		parent:  parent,
+		metrics: make(map[string]Metric),
+	}
+}
+
+// Track adding metric to tracker of registry and to metrics of collector
+func (t *collectorTracker) Track(m Metric) {
+	t.mu.Lock()
+	t.metrics[m.Desc().Name] = m
+	t.mu.Unlock()
+
+	t.parent.Track(m)
+}
+
+// Untrack removing metric from tracker of registry and from metrics of collector
+func (t *collectorTracker) Untrack(m Metric) {
+	t.mu.Lock()
+	delete(t.metrics, m.Desc().Name)
+	t.mu.Unlock()
+
+	t.parent.Untrack(m)
+}
+
+// Status returning state of metrics of collector
+func (t *collectorTracker) Status() []Status {
+	t.mu.Lock()
+
+	names := make([]string, 0, len(t.metrics))
+	metrics := make([]Metric, 0, len(t.metrics))
+
+	for name, m := range t.metrics {
+		names = append(names, name)
+		metrics = append(metrics, m)
+	}
+
+	t.mu.Unlock()
+
+	return metricsStatus(names, metrics)
+}
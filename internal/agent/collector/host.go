@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mtrrun/internal/agent"
+	"github.com/mtrrun/internal/host"
+)
+
+// Names of host metrics
+const (
+	MetricTotalMemory          = "TotalMemory"
+	MetricFreeMemory           = "FreeMemory"
+	MetricAvailableMemory      = "AvailableMemory"
+	MetricLoadAverage1         = "LoadAverage1"
+	MetricLoadAverage5         = "LoadAverage5"
+	MetricLoadAverage15        = "LoadAverage15"
+	MetricCPUUtilization       = "CPUutilization" // with number of core from 1
+	MetricDiskReadBytesPerSec  = "DiskReadBytesPerSec"
+	MetricDiskWriteBytesPerSec = "DiskWriteBytesPerSec"
+	MetricNetReceivedBytes     = "NetReceivedBytes"
+	MetricNetTransmittedBytes  = "NetTransmittedBytes"
+	MetricFilesystemTotal      = "FilesystemTotal" // with suffix of mount point, e.g. FilesystemTotal_root
+	MetricFilesystemFree       = "FilesystemFree"  // with suffix of mount point
+	MetricFilesystemUsed       = "FilesystemUsed"  // with suffix of mount point
+)
+
+// HostName name of host collector
+const HostName = "host"
+
+// Host collector of metrics of Linux host from /proc and /sys
+type Host struct {
+	reader *host.Reader
+
+	gauges      map[string]agent.Gauge
+	received    agent.Counter
+	transmitted agent.Counter
+
+	// Previous values of network counters of host. Counters of host are totals since boot,
+	// so the first read is only a baseline, else every restart of agent would add them to server again
+	prevReceived    uint64
+	prevTransmitted uint64
+	hasPrev         bool
+	tracked         bool
+}
+
+// NewHost constructor for Host
+func NewHost(c *host.Config) *Host {
+	h := &Host{
+		reader:      host.New(c),
+		gauges:      make(map[string]agent.Gauge),
+		received:    agent.NewCounter(MetricNetReceivedBytes, "bytes received by all interfaces except loopback"),
+		transmitted: agent.NewCounter(MetricNetTransmittedBytes, "bytes transmitted by all interfaces except loopback"),
+	}
+
+	for _, name := range []string{
+		MetricTotalMemory, MetricFreeMemory, MetricAvailableMemory,
+		MetricLoadAverage1, MetricLoadAverage5, MetricLoadAverage15,
+		MetricDiskReadBytesPerSec, MetricDiskWriteBytesPerSec,
+	} {
+		h.gauges[name] = agent.NewGauge(name, "")
+	}
+
+	return h
+}
+
+// Name of collector
+func (h *Host) Name() string {
+	return HostName
+}
+
+// Describe returning descriptions of metrics except metrics
+// of cores and file systems, which are known after first collect
+func (h *Host) Describe() []agent.Description {
+	return []agent.Description{
+		h.gauges[MetricTotalMemory].Desc(),
+		h.gauges[MetricFreeMemory].Desc(),
+		h.gauges[MetricAvailableMemory].Desc(),
+		h.gauges[MetricLoadAverage1].Desc(),
+		h.gauges[MetricLoadAverage5].Desc(),
+		h.gauges[MetricLoadAverage15].Desc(),
+		h.gauges[MetricDiskReadBytesPerSec].Desc(),
+		h.gauges[MetricDiskWriteBytesPerSec].Desc(),
+		h.received.Desc(),
+		h.transmitted.Desc(),
+	}
+}
+
+// Collect reading metrics of host
+func (h *Host) Collect(ctx context.Context, t agent.Tracker) error {
+	// Reading of files could not be cancelled, so context is checked before it
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s, err := h.reader.Read()
+	if err != nil {
+		return err
+	}
+
+	if !h.tracked {
+		for _, g := range h.gauges {
+			t.Track(g)
+		}
+
+		t.Track(h.received)
+		t.Track(h.transmitted)
+		h.tracked = true
+	}
+
+	h.gauges[MetricTotalMemory].Set(s.TotalMemory)
+	h.gauges[MetricFreeMemory].Set(s.FreeMemory)
+	h.gauges[MetricAvailableMemory].Set(s.AvailableMemory)
+	h.gauges[MetricLoadAverage1].Set(s.Load1)
+	h.gauges[MetricLoadAverage5].Set(s.Load5)
+	h.gauges[MetricLoadAverage15].Set(s.Load15)
+	h.gauges[MetricDiskReadBytesPerSec].Set(s.DiskReadBytesPerSec)
+	h.gauges[MetricDiskWriteBytesPerSec].Set(s.DiskWriteBytesPerSec)
+
+	for i, v := range s.CPUUtilization {
+		h.gauge(t, fmt.Sprintf("%s%d", MetricCPUUtilization, i+1)).Set(v)
+	}
+
+	for _, fs := range s.Filesystems {
+		suffix := filesystemSuffix(fs.Path)
+
+		h.gauge(t, MetricFilesystemTotal+suffix).Set(fs.Total)
+		h.gauge(t, MetricFilesystemFree+suffix).Set(fs.Free)
+		h.gauge(t, MetricFilesystemUsed+suffix).Set(fs.Used)
+	}
+
+	// Counters of host are reset when interface is recreated, such values are skipped
+	if h.hasPrev && s.NetReceivedBytes >= h.prevReceived {
+		h.received.Add(int64(s.NetReceivedBytes - h.prevReceived))
+	}
+
+	if h.hasPrev && s.NetTransmittedBytes >= h.prevTransmitted {
+		h.transmitted.Add(int64(s.NetTransmittedBytes - h.prevTransmitted))
+	}
+
+	h.prevReceived, h.prevTransmitted = s.NetReceivedBytes, s.NetTransmittedBytes
+	h.hasPrev = true
+
+	return nil
+}
+
+// gauge returning gauge by name. Gauge is created and tracked if it doesn't exist
+func (h *Host) gauge(t agent.Tracker, name string) agent.Gauge {
+	g, ok := h.gauges[name]
+	if !ok {
+		g = agent.NewGauge(name, "")
+		h.gauges[name] = g
+		t.Track(g)
+	}
+
+	return g
+}
+
+// filesystemSuffix making suffix of metric name from mount point,
+// because names are used in URL path: "/" is "_root", "/var/lib" is "_var_lib"
+func filesystemSuffix(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "_root"
+	}
+
+	return "_" + strings.ReplaceAll(path, "/", "_")
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mtrrun/internal/agent"
+	"github.com/mtrrun/internal/host"
+	"github.com/mtrrun/internal/host/hosttest"
+)
+
+func TestHostCollect(t *testing.T) {
+	h := NewHost(&host.Config{
+		ProcRoot: "../../host/testdata/proc",
+		SysRoot:  "../../host/testdata/sys",
+	})
+
+	tr := agent.NewTracker()
+	require.NoError(t, h.Collect(context.Background(), tr))
+
+	values := make(map[string]string)
+	for _, s := range tr.Status() {
+		values[s.Name] = s.Value.String()
+	}
+
+	require.Equal(t, "16694718464", values[MetricTotalMemory])
+	require.Equal(t, "20", values[MetricCPUUtilization+"1"])
+	require.Equal(t, "20", values[MetricCPUUtilization+"2"])
+	// The first read of network counters is a baseline
+	require.Equal(t, "0", values[MetricNetReceivedBytes])
+
+	// Counters are not increased when values of host are the same
+	require.NoError(t, h.Collect(context.Background(), tr))
+	require.Equal(t, "0", h.received.Value().String())
+}
+
+func TestHostNetworkRestart(t *testing.T) {
+	root := hosttest.Fixtures(t)
+	proc := filepath.Join(root, "proc")
+
+	c := &host.Config{
+		ProcRoot: proc,
+		SysRoot:  filepath.Join(root, "sys"),
+	}
+
+	h := NewHost(c)
+	require.NoError(t, h.Collect(context.Background(), agent.NewTracker()))
+
+	writeNetDev(t, proc, 1001000, 200500)
+	require.NoError(t, h.Collect(context.Background(), agent.NewTracker()))
+	require.Equal(t, "500", h.received.Value().String())
+	require.Equal(t, "200", h.transmitted.Value().String())
+
+	// Restarted agent doesn't report totals of host since boot
+	restarted := NewHost(c)
+	require.NoError(t, restarted.Collect(context.Background(), agent.NewTracker()))
+	require.Equal(t, "0", restarted.received.Value().String())
+	require.Equal(t, "0", restarted.transmitted.Value().String())
+}
+
+// writeNetDev writing /proc/net/dev with one interface
+func writeNetDev(t *testing.T, proc string, received, transmitted int) {
+	data := fmt.Sprintf(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+  eth0: %d  1000    0    0    0     0          0         0   %d    800    0    0    0     0       0          0
+`, received, transmitted)
+
+	require.NoError(t, os.WriteFile(filepath.Join(proc, "net", "dev"), []byte(data), 0o600))
+}
+
+func TestFilesystemSuffix(t *testing.T) {
+	require.Equal(t, "_root", filesystemSuffix("/"))
+	require.Equal(t, "_var_lib", filesystemSuffix("/var/lib/"))
+}
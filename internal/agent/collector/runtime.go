@@ -0,0 +1,284 @@
+// Package collector have implementations of agent.Collector
+package collector
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"runtime/metrics"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/mtrrun/internal/agent"
+)
+
+// Legacy names of metrics from runtime.MemStats. They are collected in compatibility mode.
+// Some names differ from fields of runtime.MemStats, they are kept for compatibility with stored metrics
+const (
+	MetricAlloc       = "Alloc"
+	MetricBuckHashSys = "BuckHashSys"
+	MetricFrees       = "Frees"
+	MetricGCCPUFracti = "GCCPUFracti"
+	MetricGCSys       = "GCSys"
+	MetricHeapAlloc   = "HeapAlloc"
+	MetricHeapIdle    = "HeapIdle"
+	MetricHeapInuse   = "HeapInuse"
+	MetricHeapObjects = "HeapObjects"
+	MetricHeapRelease = "HeapRelease"
+	MetricHeapSys     = "HeapSys"
+	MetricLastGC      = "LastGC"
+	MetricLookups     = "Lookups"
+	MetricMCacheInuse = "MCacheSys"
+	MetricMCacheSys   = "CacheSys"
+	MetricMSpanInuse  = "MSpanInuse"
+	MetricMSpanSys    = "MSpanSys"
+	MetricMallocs     = "Mallocs"
+	MetricNextGC      = "NextGC"
+	MetricNumForcedGC = "NumForcedGC"
+	MetricNumGC       = "NumGC"
+	MetricOtherSys    = "OtherSys"
+	MetricPauseTotalN = "PauseTotalN"
+	MetricStackInuse  = "StackInuse"
+	MetricStackSys    = "StackSys"
+	MetricSys         = "Sys"
+	MetricTotalAlloc  = "TotalAlloc"
+
+	// PollCount is incremented by 1 each time metrics from runtime are updated
+	MetricPollCount = "PollCount"
+	// RandomValue is random value
+	MetricRandomValue = "RandomValue"
+
+	MetricGoroutines = "Goroutines"
+	MetricThreads    = "Threads"
+
+	// Prefix of metrics from runtime/metrics
+	runtimeMetricPrefix = "go_"
+)
+
+// legacyNames names of metrics from runtime.MemStats
+var legacyNames = []string{
+	MetricAlloc, MetricBuckHashSys, MetricFrees, MetricGCCPUFracti, MetricGCSys,
+	MetricHeapAlloc, MetricHeapIdle, MetricHeapInuse, MetricHeapObjects, MetricHeapRelease,
+	MetricHeapSys, MetricLastGC, MetricLookups, MetricMCacheInuse, MetricMCacheSys,
+	MetricMSpanInuse, MetricMSpanSys, MetricMallocs, MetricNextGC, MetricNumForcedGC,
+	MetricNumGC, MetricOtherSys, MetricPauseTotalN, MetricStackInuse, MetricStackSys,
+	MetricSys, MetricTotalAlloc,
+}
+
+// RuntimeName name of runtime collector
+const RuntimeName = "runtime"
+
+// RuntimeConfig configuration list for Runtime
+type RuntimeConfig struct {
+	// Compat enables legacy metrics from runtime.MemStats with their old names
+	Compat bool
+}
+
+// Runtime collector of Go runtime of agent process. All metrics which are
+// supported by runtime/metrics are discovered automatically and named
+// as "go_" with name of metric where "/" and ":" are replaced with "_",
+// e.g. /gc/heap/allocs:bytes is go_gc_heap_allocs_bytes.
+// Cumulative integer metrics are counters, other scalar metrics are gauges
+// and distributions are histograms.
+type Runtime struct {
+	compat bool
+
+	samples    []metrics.Sample
+	gauges     map[string]agent.Gauge
+	counters   map[string]agent.Counter
+	histograms map[string]agent.Histogram
+
+	// Previous values of cumulative runtime metrics, counters get differences
+	prev map[string]uint64
+
+	legacy    map[string]agent.Gauge
+	pollCount agent.Counter
+	random    agent.Gauge
+	rnd       *rand.Rand
+
+	goroutines agent.Gauge
+	threads    agent.Gauge
+
+	tracked bool
+}
+
+// NewRuntime constructor for Runtime
+func NewRuntime(c *RuntimeConfig) *Runtime {
+	r := &Runtime{
+		compat:     c.Compat,
+		gauges:     make(map[string]agent.Gauge),
+		counters:   make(map[string]agent.Counter),
+		histograms: make(map[string]agent.Histogram),
+		prev:       make(map[string]uint64),
+		legacy:     make(map[string]agent.Gauge),
+		pollCount:  agent.NewCounter(MetricPollCount, "count of runtime metrics updates"),
+		random:     agent.NewGauge(MetricRandomValue, "random value"),
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		goroutines: agent.NewGauge(MetricGoroutines, "count of goroutines"),
+		threads:    agent.NewGauge(MetricThreads, "count of created OS threads"),
+	}
+
+	for _, d := range metrics.All() {
+		name := runtimeMetricName(d.Name)
+
+		switch d.Kind {
+		case metrics.KindUint64:
+			if d.Cumulative {
+				r.counters[d.Name] = agent.NewCounter(name, d.Description)
+			} else {
+				r.gauges[d.Name] = agent.NewGauge(name, d.Description)
+			}
+		case metrics.KindFloat64:
+			r.gauges[d.Name] = agent.NewGauge(name, d.Description)
+		case metrics.KindFloat64Histogram:
+			r.histograms[d.Name] = agent.NewHistogram(name, d.Description)
+		default:
+			// Kinds which are added in newer versions of Go
+			continue
+		}
+
+		r.samples = append(r.samples, metrics.Sample{Name: d.Name})
+	}
+
+	if r.compat {
+		for _, name := range legacyNames {
+			r.legacy[name] = agent.NewGauge(name, "")
+		}
+	}
+
+	return r
+}
+
+// Name of collector
+func (r *Runtime) Name() string {
+	return RuntimeName
+}
+
+// Describe returning descriptions of all metrics
+func (r *Runtime) Describe() []agent.Description {
+	result := make([]agent.Description, 0)
+
+	for _, m := range r.metrics() {
+		result = append(result, m.Desc())
+	}
+
+	return result
+}
+
+// Collect reading runtime/metrics and runtime.MemStats in compatibility mode
+func (r *Runtime) Collect(_ context.Context, t agent.Tracker) error {
+	if !r.tracked {
+		for _, m := range r.metrics() {
+			t.Track(m)
+		}
+
+		r.tracked = true
+	}
+
+	metrics.Read(r.samples)
+
+	for _, s := range r.samples {
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			v := s.Value.Uint64()
+
+			if c, ok := r.counters[s.Name]; ok {
+				if v >= r.prev[s.Name] {
+					c.Add(int64(v - r.prev[s.Name]))
+				}
+
+				r.prev[s.Name] = v
+			} else if g, ok := r.gauges[s.Name]; ok {
+				g.Set(float64(v))
+			}
+		case metrics.KindFloat64:
+			if g, ok := r.gauges[s.Name]; ok {
+				g.Set(s.Value.Float64())
+			}
+		case metrics.KindFloat64Histogram:
+			if h, ok := r.histograms[s.Name]; ok {
+				v := s.Value.Float64Histogram()
+				h.Set(v.Counts, v.Buckets)
+			}
+		}
+	}
+
+	r.goroutines.Set(float64(runtime.NumGoroutine()))
+	r.threads.Set(float64(pprof.Lookup("threadcreate").Count()))
+
+	if r.compat {
+		r.collectLegacy()
+	}
+
+	r.random.Set(float64(r.rnd.Int63()))
+	r.pollCount.Inc()
+
+	return nil
+}
+
+// collectLegacy reading runtime.MemStats
+func (r *Runtime) collectLegacy() {
+	m := &runtime.MemStats{}
+	runtime.ReadMemStats(m)
+
+	r.legacy[MetricAlloc].Set(float64(m.Alloc))
+	r.legacy[MetricBuckHashSys].Set(float64(m.BuckHashSys))
+	r.legacy[MetricFrees].Set(float64(m.Frees))
+	r.legacy[MetricGCCPUFracti].Set(m.GCCPUFraction)
+	r.legacy[MetricGCSys].Set(float64(m.GCSys))
+	r.legacy[MetricHeapAlloc].Set(float64(m.HeapAlloc))
+	r.legacy[MetricHeapIdle].Set(float64(m.HeapIdle))
+	r.legacy[MetricHeapInuse].Set(float64(m.HeapInuse))
+	r.legacy[MetricHeapObjects].Set(float64(m.HeapObjects))
+	r.legacy[MetricHeapRelease].Set(float64(m.HeapReleased))
+	r.legacy[MetricHeapSys].Set(float64(m.HeapSys))
+	r.legacy[MetricLastGC].Set(float64(m.LastGC))
+	r.legacy[MetricLookups].Set(float64(m.Lookups))
+	r.legacy[MetricMCacheInuse].Set(float64(m.MCacheInuse))
+	r.legacy[MetricMCacheSys].Set(float64(m.MCacheSys))
+	r.legacy[MetricMSpanInuse].Set(float64(m.MSpanInuse))
+	r.legacy[MetricMSpanSys].Set(float64(m.MSpanSys))
+	r.legacy[MetricMallocs].Set(float64(m.Mallocs))
+	r.legacy[MetricNextGC].Set(float64(m.NextGC))
+	r.legacy[MetricNumForcedGC].Set(float64(m.NumForcedGC))
+	r.legacy[MetricNumGC].Set(float64(m.NumGC))
+	r.legacy[MetricOtherSys].Set(float64(m.OtherSys))
+	r.legacy[MetricPauseTotalN].Set(float64(m.PauseTotalNs))
+	r.legacy[MetricStackInuse].Set(float64(m.StackInuse))
+	r.legacy[MetricStackSys].Set(float64(m.StackSys))
+	r.legacy[MetricSys].Set(float64(m.Sys))
+	r.legacy[MetricTotalAlloc].Set(float64(m.TotalAlloc))
+}
+
+// metrics returning all metrics of collector
+func (r *Runtime) metrics() []agent.Metric {
+	result := make([]agent.Metric, 0, len(r.gauges)+len(r.counters)+len(r.histograms)+len(r.legacy)+4)
+
+	for _, g := range r.gauges {
+		result = append(result, g)
+	}
+
+	for _, c := range r.counters {
+		result = append(result, c)
+	}
+
+	for _, h := range r.histograms {
+		result = append(result, h)
+	}
+
+	for _, g := range r.legacy {
+		result = append(result, g)
+	}
+
+	return append(result, r.pollCount, r.random, r.goroutines, r.threads)
+}
+
+// runtimeMetricName making name of metric from name in runtime/metrics,
+// because names are used in URL path: /gc/heap/allocs:bytes is go_gc_heap_allocs_bytes
+func runtimeMetricName(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	name = strings.NewReplacer("/", "_", ":", "_", "-", "_", "*", "x").Replace(name)
+
+	return runtimeMetricPrefix + name
+}
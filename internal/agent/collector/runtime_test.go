@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mtrrun/internal/agent"
+)
+
+// statusTypes returning types of tracked metrics by name
+func statusTypes(t agent.Tracker) map[string]string {
+	result := make(map[string]string)
+
+	for _, s := range t.Status() {
+		result[s.Name] = s.MetricType
+	}
+
+	return result
+}
+
+func TestRuntimeCollect(t *testing.T) {
+	tr := agent.NewTracker()
+
+	r := NewRuntime(&RuntimeConfig{})
+	require.NoError(t, r.Collect(context.Background(), tr))
+
+	types := statusTypes(tr)
+
+	require.Equal(t, "gauge", types["go_sched_goroutines_goroutines"])
+	require.Equal(t, "counter", types["go_gc_heap_allocs_bytes"])
+	require.Equal(t, "gauge", types["go_sched_latencies_seconds_p99"])
+	require.Equal(t, "counter", types["go_sched_latencies_seconds_count"])
+	require.Equal(t, "gauge", types[MetricGoroutines])
+	require.Equal(t, "gauge", types[MetricThreads])
+	require.Equal(t, "counter", types[MetricPollCount])
+
+	// Legacy names are collected only in compatibility mode
+	require.NotContains(t, types, MetricHeapAlloc)
+
+	tr = agent.NewTracker()
+
+	r = NewRuntime(&RuntimeConfig{Compat: true})
+	require.NoError(t, r.Collect(context.Background(), tr))
+	require.Equal(t, "gauge", statusTypes(tr)[MetricHeapAlloc])
+}
+
+func TestRuntimeMetricName(t *testing.T) {
+	require.Equal(t, "go_gc_heap_allocs_bytes", runtimeMetricName("/gc/heap/allocs:bytes"))
+	require.Equal(t, "go_cpu_classes_gc_mark_assist_cpu_seconds", runtimeMetricName("/cpu/classes/gc/mark/assist:cpu-seconds"))
+}
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCollector struct {
+	name    string
+	metrics []Description
+	collect func(ctx context.Context, t Tracker) error
+}
+
+func (c *testCollector) Name() string {
+	return c.name
+}
+
+func (c *testCollector) Describe() []Description {
+	return c.metrics
+}
+
+func (c *testCollector) Collect(ctx context.Context, t Tracker) error {
+	return c.collect(ctx, t)
+}
+
+// counterValue returning value of counter from tracker or -1 if it is not tracked
+func counterValue(t Tracker, name string) int64 {
+	for _, s := range t.Status() {
+		if s.Name == name {
+			return s.Value.Int64()
+		}
+	}
+
+	return -1
+}
+
+func TestRegistryRegister(t *testing.T) {
+	r := NewRegistry(NewTracker(), time.Second)
+
+	first := &testCollector{name: "first", metrics: []Description{{Name: "a"}}}
+	require.NoError(t, r.Register(first, &CollectorConfig{}))
+
+	// Same name of collector
+	require.Error(t, r.Register(&testCollector{name: "first"}, &CollectorConfig{}))
+
+	// Same name of metric
+	require.Error(t, r.Register(&testCollector{name: "second", metrics: []Description{{Name: "a"}}}, &CollectorConfig{}))
+}
+
+func TestRegistryIsolatesCollectors(t *testing.T) {
+	tr := NewTracker()
+	r := NewRegistry(tr, 20*time.Millisecond)
+
+	good := NewCounter("Good", "")
+
+	require.NoError(t, r.Register(&testCollector{
+		name: "good",
+		collect: func(ctx context.Context, t Tracker) error {
+			t.Track(good)
+			good.Inc()
+
+			return nil
+		},
+	}, &CollectorConfig{}))
+
+	require.NoError(t, r.Register(&testCollector{
+		name: "panic",
+		collect: func(ctx context.Context, t Tracker) error {
+			panic("boom")
+		},
+	}, &CollectorConfig{}))
+
+	require.NoError(t, r.Register(&testCollector{
+		name: "error",
+		collect: func(ctx context.Context, t Tracker) error {
+			return errors.New("failed")
+		},
+	}, &CollectorConfig{}))
+
+	slow := make(chan struct{})
+
+	require.NoError(t, r.Register(&testCollector{
+		name: "slow",
+		collect: func(ctx context.Context, t Tracker) error {
+			<-slow
+
+			return nil
+		},
+	}, &CollectorConfig{Interval: time.Hour, Timeout: 10 * time.Millisecond}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		r.Run(ctx)
+		close(stopped)
+	}()
+
+	require.Eventually(t, func() bool {
+		return counterValue(tr, "Good") >= 3 &&
+			counterValue(tr, "CollectorErrors_panic") >= 3 &&
+			counterValue(tr, "CollectorErrors_error") >= 3 &&
+			counterValue(tr, "CollectorErrors_slow") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, int64(0), counterValue(tr, "CollectorErrors_good"))
+
+	cancel()
+	close(slow)
+	<-stopped
+}
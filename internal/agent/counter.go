@@ -1,43 +1,38 @@
 package agent
 
 import (
-	"fmt"
-	"sync"
+	"sync/atomic"
 )
 
-// Implementing Counter interface
+// Implementing Counter interface without locks
 type counter struct {
-	mu  sync.RWMutex
+	// Must be first field for 64-bit alignment of atomic operations on 32-bit platforms
 	val int64
 	d   *Description
 }
 
+// Desc returning description. It is immutable, so lock is not needed
 func (c *counter) Desc() Description {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
 	return *c.d
 }
 
 // Inc increments the Counter by 1. Use Add to increment it by arbitrary values
 func (c *counter) Inc() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.val++
+	atomic.AddInt64(&c.val, 1)
 }
 
-// GetValue returned value
-func (c *counter) GetValue() string {
-	var value int64
-
-	c.mu.RLock()
-
-	value = c.val
+// Add adds the given value to the Counter. Negative values are ignored, counter can only increase
+func (c *counter) Add(val int64) {
+	if val < 0 {
+		return
+	}
 
-	c.mu.RUnlock()
+	atomic.AddInt64(&c.val, val)
+}
 
-	return fmt.Sprintf("%d", value)
+// Value returning actual value
+func (c *counter) Value() Value {
+	return Int64Value(atomic.LoadInt64(&c.val))
 }
 
 func NewCounter(name string, help string) Counter {
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -16,3 +17,68 @@ func TestNewCounter(t *testing.T) {
 	require.Equal(t, name, d.Name)
 	require.Equal(t, help, d.Help)
 }
+
+func TestCounterAdd(t *testing.T) {
+	c := NewCounter("test", "")
+
+	c.Inc()
+	c.Add(10)
+	c.Add(-5)
+
+	require.Equal(t, int64(11), c.Value().Int64())
+}
+
+func TestCounterConcurrentInc(t *testing.T) {
+	c := NewCounter("test", "")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				c.Inc()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.Equal(t, int64(8000), c.Value().Int64())
+}
+
+// mutexCounter is previous implementation of counter, it is kept for comparison in benchmarks
+type mutexCounter struct {
+	mu  sync.RWMutex
+	val int64
+}
+
+func (c *mutexCounter) Inc() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.val++
+}
+
+func BenchmarkCounterInc(b *testing.B) {
+	c := NewCounter("test", "")
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc()
+		}
+	})
+}
+
+func BenchmarkCounterIncMutex(b *testing.B) {
+	c := &mutexCounter{}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc()
+		}
+	})
+}
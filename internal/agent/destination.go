@@ -0,0 +1,271 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mtrrun/internal/envelope"
+	"github.com/mtrrun/internal/model"
+	"github.com/mtrrun/internal/spool"
+	"github.com/mtrrun/internal/tlsconfig"
+)
+
+// Destination is server which receives reports. Every destination has its own client,
+// workers and spool, so slow or unavailable server doesn't delay other destinations.
+// Options have the same meaning and default values as options of Config
+type Destination struct {
+	// Name of destination in logs. If Name is empty that will be use Host
+	Name string
+
+	Host string
+
+	// Client for sending reports. If Client is empty that will be use http client
+	// configured with options below
+	Client Client
+
+	MaxRequestsPerMoment int
+	QueueSize            int
+	RequestsPerSecond    float64
+	Burst                int
+
+	Timeout            time.Duration
+	MaxIdleConns       int
+	DisableCompression bool
+
+	// Credentials and TLS options are not taken from Config unless InheritCredentials is set,
+	// so token or key of one server is not sent to other servers
+	InheritCredentials bool
+
+	Key       string
+	CryptoKey string
+
+	TLSCA         string
+	TLSCert       string
+	TLSKey        string
+	TLSServerName string
+
+	Token     string
+	TokenFile string
+
+	Retries              int
+	RetryInitialInterval time.Duration
+	RetryMaxInterval     time.Duration
+
+	SpoolDir     string
+	SpoolMaxSize int64
+	SpoolMaxAge  time.Duration
+}
+
+// destination with its client and workers
+type destination struct {
+	name   string
+	host   string
+	scheme string
+
+	client Client
+
+	// Workers for sending requests and limit of requests per second for all of them
+	pool    *pool
+	limiter *tokenBucket
+
+	// Queue on disk for reports which were not delivered. Nil if it is disabled
+	spool *spool.Queue
+}
+
+// defaultDestination returning destination from options of Config
+// which are used when Destinations is empty
+func (c *Config) defaultDestination() Destination {
+	return Destination{
+		Host:                 c.Host,
+		Client:               c.Client,
+		MaxRequestsPerMoment: c.MaxRequestsPerMoment,
+		QueueSize:            c.QueueSize,
+		RequestsPerSecond:    c.RequestsPerSecond,
+		Burst:                c.Burst,
+		Timeout:              c.Timeout,
+		MaxIdleConns:         c.MaxIdleConns,
+		DisableCompression:   c.DisableCompression,
+		Key:                  c.Key,
+		CryptoKey:            c.CryptoKey,
+		TLSCA:                c.TLSCA,
+		TLSCert:              c.TLSCert,
+		TLSKey:               c.TLSKey,
+		TLSServerName:        c.TLSServerName,
+		Token:                c.Token,
+		TokenFile:            c.TokenFile,
+		Retries:              c.Retries,
+		RetryInitialInterval: c.RetryInitialInterval,
+		RetryMaxInterval:     c.RetryMaxInterval,
+		SpoolDir:             c.SpoolDir,
+		SpoolMaxSize:         c.SpoolMaxSize,
+		SpoolMaxAge:          c.SpoolMaxAge,
+	}
+}
+
+// newDestination creating client, workers and spool of destination.
+// Requests are printed instead of sending if printer is not nil
+func newDestination(d *Destination, reportInterval time.Duration, printer *dryRunPrinter) (*destination, error) {
+	if d.Host == "" {
+		return nil, fmt.Errorf("host of destination %q is empty", d.Name)
+	}
+
+	if d.Name == "" {
+		d.Name = d.Host
+	}
+
+	if d.MaxRequestsPerMoment <= 0 {
+		d.MaxRequestsPerMoment = defaultMaxRequestsPerMoment
+	}
+
+	if d.QueueSize <= 0 {
+		d.QueueSize = defaultQueueSize
+	}
+
+	if d.Retries == 0 {
+		d.Retries = defaultRetries
+	}
+
+	var encrypter *envelope.Encrypter
+
+	if d.CryptoKey != "" {
+		pub, err := envelope.ReadPublicKey(d.CryptoKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read public key: %w", err)
+		}
+
+		encrypter = envelope.NewEncrypter(pub)
+	}
+
+	var tlsConfig *tls.Config
+
+	scheme := "http"
+
+	if d.TLSCA != "" || d.TLSCert != "" || d.TLSKey != "" || d.TLSServerName != "" {
+		var err error
+
+		tlsConfig, err = tlsconfig.Client(&tlsconfig.ClientConfig{
+			CAFile:     d.TLSCA,
+			CertFile:   d.TLSCert,
+			KeyFile:    d.TLSKey,
+			ServerName: d.TLSServerName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure TLS: %w", err)
+		}
+
+		scheme = "https"
+	}
+
+	var transport http.RoundTripper
+
+	if printer != nil {
+		transport = printer.transport(d.Key)
+	}
+
+	var queue *spool.Queue
+
+	// Dry run must not send or drop reports which were stored by real runs
+	if d.SpoolDir != "" && printer == nil {
+		var err error
+
+		queue, err = spool.Open(&spool.Config{
+			Dir:     d.SpoolDir,
+			MaxSize: d.SpoolMaxSize,
+			MaxAge:  d.SpoolMaxAge,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to open spool: %w", err)
+		}
+	}
+
+	client := d.Client
+	if client == nil {
+		client = NewClient(&ClientConfig{
+			Timeout:            d.Timeout,
+			MaxIdleConns:       d.MaxIdleConns,
+			DisableCompression: d.DisableCompression,
+			Key:                d.Key,
+			Encrypter:          encrypter,
+			TLSConfig:          tlsConfig,
+			RealIP:             outboundIP(d.Host),
+			Token:              NewTokenSource(d.Token, d.TokenFile),
+			Retry: RetryPolicy{
+				MaxRetries:      d.Retries,
+				InitialInterval: d.RetryInitialInterval,
+				MaxInterval:     d.RetryMaxInterval,
+				MaxElapsedTime:  reportInterval,
+			},
+			Transport: transport,
+		})
+	}
+
+	limiter := newTokenBucket(d.RequestsPerSecond, d.Burst)
+
+	return &destination{
+		name:    d.Name,
+		host:    d.Host,
+		scheme:  scheme,
+		client:  client,
+		pool:    newPool(d.MaxRequestsPerMoment, d.QueueSize, limiter),
+		limiter: limiter,
+		spool:   queue,
+	}, nil
+}
+
+// send sending every metric in separate request through worker pool
+// and returning metrics which were not delivered
+func (d *destination) send(ctx context.Context, batch []model.Metrics) []model.Metrics {
+	results := make([]chan error, len(batch))
+
+	// Results are read after all tasks are submitted, so channels are buffered
+	for i := range batch {
+		m := batch[i]
+		results[i] = make(chan error, 1)
+
+		d.pool.Submit(ctx, func(ctx context.Context) error {
+			body, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+
+			url := d.url("/update/")
+
+			log.Printf("start of request to url: %s with metric %s\n", url, m.ID)
+
+			return d.client.DoRequest(ctx, http.MethodPost, url, map[string]string{contentTypeHeader: defaultContentType}, body)
+		}, results[i])
+	}
+
+	failed := make([]model.Metrics, 0)
+
+	for i := range batch {
+		if err := <-results[i]; err != nil {
+			log.Printf("request with metric %s to %s ended with error: %s\n", batch[i].ID, d.name, err)
+			failed = append(failed, batch[i])
+		}
+	}
+
+	return failed
+}
+
+// url returning address of server endpoint
+func (d *destination) url(path string) string {
+	return fmt.Sprintf("%s://%s%s", d.scheme, d.host, path)
+}
+
+// close stopping workers and closing connections and spool. Workers drain their queue before
+func (d *destination) close() {
+	d.pool.Stop()
+	d.client.Shutdown()
+
+	if d.spool != nil {
+		if err := d.spool.Close(); err != nil {
+			log.Printf("unable to close spool of %s: %s\n", d.name, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mtrrun/internal/model"
+)
+
+// recorder is test server which remembers received metrics
+type recorder struct {
+	mu       sync.Mutex
+	status   int
+	received []model.Metrics
+	srv      *httptest.Server
+}
+
+func newRecorder(t *testing.T, status int) *recorder {
+	r := &recorder{status: status}
+
+	r.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var m model.Metrics
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&m))
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.status == http.StatusOK {
+			r.received = append(r.received, m)
+		}
+
+		w.WriteHeader(r.status)
+	}))
+
+	t.Cleanup(r.srv.Close)
+
+	return r
+}
+
+func (r *recorder) host() string {
+	return strings.TrimPrefix(r.srv.URL, "http://")
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.received)
+}
+
+func TestAgentFanOut(t *testing.T) {
+	first := newRecorder(t, http.StatusOK)
+	second := newRecorder(t, http.StatusOK)
+	down := newRecorder(t, http.StatusServiceUnavailable)
+
+	a, err := New(&Config{
+		ReportInterval:     time.Second,
+		Retries:            -1,
+		DisableCompression: true,
+		Destinations: []Destination{
+			{Name: "first", Host: first.host()},
+			{Name: "second", Host: second.host()},
+			{Name: "down", Host: down.host()},
+		},
+	})
+	require.NoError(t, err)
+
+	c := NewCounter("Requests", "")
+	c.Add(3)
+	a.Track(c)
+
+	// Unavailable destination doesn't prevent delivery to others
+	err = a.Once(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "down")
+	require.NotContains(t, err.Error(), "first")
+
+	require.Equal(t, 1, first.count())
+	require.Equal(t, 1, second.count())
+	require.Equal(t, int64(3), *first.received[0].Delta)
+	require.Equal(t, int64(3), *second.received[0].Delta)
+}
+
+func TestAgentFailover(t *testing.T) {
+	primary := newRecorder(t, http.StatusServiceUnavailable)
+	backup := newRecorder(t, http.StatusOK)
+
+	a, err := New(&Config{
+		ReportInterval:     time.Second,
+		Retries:            -1,
+		DisableCompression: true,
+		Failover:           true,
+		Destinations: []Destination{
+			{Name: "primary", Host: primary.host()},
+			{Name: "backup", Host: backup.host()},
+		},
+	})
+	require.NoError(t, err)
+
+	g := NewGauge("Load", "")
+	g.Set(1.5)
+	a.Track(g)
+
+	require.NoError(t, a.Once(context.Background()))
+	require.Equal(t, 1, backup.count())
+	require.Equal(t, 1.5, *backup.received[0].Value)
+}
+
+func TestAgentFailoverSpool(t *testing.T) {
+	_, err := New(&Config{
+		Failover: true,
+		Destinations: []Destination{
+			{Host: "127.0.0.1:1", SpoolDir: t.TempDir()},
+			{Host: "127.0.0.1:2", SpoolDir: t.TempDir()},
+		},
+	})
+	require.Error(t, err)
+
+	_, err = New(&Config{
+		Destinations: []Destination{
+			{Host: "127.0.0.1:1"},
+			{Host: "127.0.0.1:1"},
+		},
+	})
+	require.Error(t, err)
+}
+
+func TestAgentSlowDestination(t *testing.T) {
+	fast := newRecorder(t, http.StatusOK)
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	a, err := New(&Config{
+		ReportInterval:     50 * time.Millisecond,
+		PollInterval:       time.Hour,
+		Retries:            -1,
+		ResyncInterval:     -1,
+		Timeout:            time.Minute,
+		DisableCompression: true,
+		ShutdownTimeout:    100 * time.Millisecond,
+		Destinations: []Destination{
+			{Name: "slow", Host: strings.TrimPrefix(slow.URL, "http://")},
+			{Name: "fast", Host: fast.host()},
+		},
+	})
+	require.NoError(t, err)
+
+	a.Track(NewGauge("Load", ""))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_ = a.Run(ctx)
+	}()
+
+	// Reports to fast destination are not delayed by report to slow one
+	require.Eventually(t, func() bool {
+		return fast.count() >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestConfigInherit(t *testing.T) {
+	c := &Config{
+		Key:      "secret",
+		Token:    "token",
+		TLSCA:    "ca.pem",
+		SpoolDir: "/var/spool",
+		Retries:  5,
+	}
+
+	// Credentials are not sent to other servers by default
+	d := c.inherit(Destination{Host: "h"})
+
+	require.Empty(t, d.Key)
+	require.Empty(t, d.Token)
+	require.Empty(t, d.TLSCA)
+	require.Equal(t, 5, d.Retries)
+
+	d = c.inherit(Destination{Host: "h", TLSServerName: "server", Retries: -1, InheritCredentials: true})
+
+	require.Equal(t, "secret", d.Key)
+	require.Equal(t, "token", d.Token)
+	require.Equal(t, -1, d.Retries)
+	require.Equal(t, "server", d.TLSServerName)
+	// TLS options are inherited only together
+	require.Empty(t, d.TLSCA)
+	// Destinations never share spool
+	require.Empty(t, d.SpoolDir)
+}
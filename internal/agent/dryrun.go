@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mtrrun/internal/envelope"
+	"github.com/mtrrun/internal/sign"
+)
+
+// Formats of requests in dry run
+const (
+	DryRunText = "text"
+	DryRunJSON = "json"
+)
+
+// Value of header "Authorization" in dry run, so token doesn't get into logs
+const redactedToken = "Bearer <redacted>"
+
+// dryRunPrinter printing requests of all destinations to one output
+type dryRunPrinter struct {
+	mu sync.Mutex
+
+	out    io.Writer
+	format string
+}
+
+// dryRunTransport printing requests instead of sending them. Every request succeeds.
+// It is transport of real client, so requests are printed with signature, compression
+// and encryption exactly as they would be sent
+type dryRunTransport struct {
+	printer *dryRunPrinter
+	key     []byte
+}
+
+// dryRunRequest is request in JSON format of dry run
+type dryRunRequest struct {
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Header map[string]string `json:"header"`
+
+	// Body is decompressed for readability, Size is size of body which would be sent.
+	// Encrypted body is not printed
+	Body      string `json:"body,omitempty"`
+	Size      int    `json:"size"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+}
+
+func newDryRunPrinter(out io.Writer, format string) (*dryRunPrinter, error) {
+	if format == "" {
+		format = DryRunText
+	}
+
+	if format != DryRunText && format != DryRunJSON {
+		return nil, fmt.Errorf("unknown format of dry run %q. Expected %s or %s", format, DryRunText, DryRunJSON)
+	}
+
+	return &dryRunPrinter{
+		out:    out,
+		format: format,
+	}, nil
+}
+
+// transport returning transport for destination. key is used for signing responses
+func (p *dryRunPrinter) transport(key string) *dryRunTransport {
+	return &dryRunTransport{
+		printer: p,
+		key:     []byte(key),
+	}
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r, err := newDryRunRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.printer.print(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	body := []byte("{}")
+	header := make(http.Header)
+
+	// Client verifies signature of responses
+	if len(t.key) > 0 {
+		header.Set(sign.Header, sign.Sum(t.key, body))
+	}
+
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func newDryRunRequest(req *http.Request) (*dryRunRequest, error) {
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		_ = req.Body.Close()
+	}
+
+	r := &dryRunRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: make(map[string]string, len(req.Header)),
+		Size:   len(body),
+	}
+
+	for k := range req.Header {
+		r.Header[k] = strings.Join(req.Header.Values(k), ", ")
+	}
+
+	if _, ok := r.Header[authorizationHeader]; ok {
+		r.Header[authorizationHeader] = redactedToken
+	}
+
+	switch {
+	case req.Header.Get(envelope.Header) != "":
+		r.Encrypted = true
+	case req.Header.Get(contentEncodingHeader) == encodingGzip && len(body) > 0:
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		if body, err = io.ReadAll(gz); err != nil {
+			return nil, err
+		}
+
+		r.Body = string(body)
+	default:
+		r.Body = string(body)
+	}
+
+	return r, nil
+}
+
+func (p *dryRunPrinter) print(r *dryRunRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.format == DryRunJSON {
+		return json.NewEncoder(p.out).Encode(r)
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s\n", r.Method, r.URL)
+
+	keys := make([]string, 0, len(r.Header))
+	for k := range r.Header {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\n", k, r.Header[k])
+	}
+
+	switch {
+	case r.Encrypted:
+		fmt.Fprintf(&b, "\n<encrypted body, %d bytes>\n", r.Size)
+	case r.Body != "":
+		fmt.Fprintf(&b, "\n%s\n", r.Body)
+	}
+
+	b.WriteString("\n")
+
+	_, err := io.WriteString(p.out, b.String())
+
+	return err
+}
@@ -0,0 +1,239 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mtrrun/internal/model"
+	"github.com/mtrrun/internal/relabel"
+)
+
+const (
+	acceptHeader = "Accept"
+
+	contentTypePrometheus  = "text/plain; version=0.0.4; charset=utf-8"
+	contentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	mediaTypeOpenMetrics   = "application/openmetrics-text"
+
+	// Suffix of samples of counters in OpenMetrics
+	openMetricsTotalSuffix = "_total"
+
+	readHeaderTimeout = 5 * time.Second
+)
+
+// Handler returning http handler for pull mode. It serves actual metrics
+// on /metrics in Prometheus text format, or in OpenMetrics format if client accepts it,
+// and on /metrics/json as list of metrics like in push mode.
+// Counters have cumulative values in both formats
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/metrics", a.serveText)
+	mux.HandleFunc("/metrics/json", a.serveJSON)
+
+	return mux
+}
+
+// serveText writing metrics in Prometheus or OpenMetrics text format
+func (a *Agent) serveText(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	openMetrics := strings.Contains(r.Header.Get(acceptHeader), mediaTypeOpenMetrics)
+
+	if openMetrics {
+		w.Header().Set(contentTypeHeader, contentTypeOpenMetrics)
+	} else {
+		w.Header().Set(contentTypeHeader, contentTypePrometheus)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	labels := exposedLabels(a.relabeler)
+
+	// Text formats support not finite values, unlike push mode
+	for _, s := range sortedStatus(a.statuses(a.disablePush)) {
+		writeFamily(bw, s, labels, openMetrics)
+	}
+
+	if openMetrics {
+		_, _ = bw.WriteString("# EOF\n")
+	}
+
+	if err := bw.Flush(); err != nil {
+		log.Printf("unable to write metrics: %s\n", err)
+	}
+}
+
+// writeFamily writing one metric with its help and type.
+// labels are static labels in braces or empty string
+func writeFamily(w *bufio.Writer, s Status, labels string, openMetrics bool) {
+	name := exposedName(s.Name)
+	sample := name
+
+	if openMetrics && s.MetricType == counterType {
+		name = strings.TrimSuffix(name, openMetricsTotalSuffix)
+		sample = name + openMetricsTotalSuffix
+	}
+
+	if s.Help != "" {
+		_, _ = w.WriteString("# HELP " + name + " " + escapeHelp(s.Help) + "\n")
+	}
+
+	mType := s.MetricType
+	if mType != gaugeType && mType != counterType {
+		mType = unknownType
+	}
+
+	_, _ = w.WriteString("# TYPE " + name + " " + mType + "\n")
+	_, _ = w.WriteString(sample + labels + " " + exposedValue(s.Value) + "\n")
+}
+
+// serveJSON writing metrics as list of model.Metrics
+func (a *Agent) serveJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	statuses := sortedStatus(a.statuses(a.disablePush))
+	list := make([]model.Metrics, 0, len(statuses))
+
+	for _, s := range statuses {
+		m, err := newMetrics(s)
+		if err != nil {
+			continue
+		}
+
+		m.Labels = a.relabeler.Labels()
+
+		list = append(list, m)
+	}
+
+	w.Header().Set(contentTypeHeader, defaultContentType)
+
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		log.Printf("unable to write metrics: %s\n", err)
+	}
+}
+
+func sortedStatus(s []Status) []Status {
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Name < s[j].Name
+	})
+
+	return s
+}
+
+// exposedName replacing characters which are not allowed in names of Prometheus metrics
+func exposedName(name string) string {
+	var b strings.Builder
+
+	for i, r := range name {
+		switch {
+		case r == '_' || r == ':' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+
+	return b.String()
+}
+
+// exposedValue formatting value. Text formats have their own names for special values
+func exposedValue(v Value) string {
+	if v.IsFinite() {
+		return v.String()
+	}
+
+	f := v.Float64()
+
+	switch {
+	case f > 0:
+		return "+Inf"
+	case f < 0:
+		return "-Inf"
+	default:
+		return "NaN"
+	}
+}
+
+// exposedLabels formatting static labels like {env="prod",host="a"}. Empty string if there are no labels
+func exposedLabels(r *relabel.Relabeler) string {
+	names := r.LabelNames()
+	if len(names) == 0 {
+		return ""
+	}
+
+	labels := r.Labels()
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, 0, len(names))
+
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+escape.Replace(labels[name])+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+// serve starting HTTP server for pull mode. Server is shut down when ctx is done,
+// wg is done after that
+func (a *Agent) serve(ctx context.Context, wg *sync.WaitGroup) error {
+	ln, err := net.Listen("tcp", a.listenAddress)
+	if err != nil {
+		return fmt.Errorf("unable to listen %s: %w", a.listenAddress, err)
+	}
+
+	srv := &http.Server{
+		Handler:           a.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		log.Printf("metrics are served on %s\n", ln.Addr())
+
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics server failed: %s\n", err)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("unable to shutdown metrics server: %s\n", err)
+		}
+	}()
+
+	return nil
+}
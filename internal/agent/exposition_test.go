@@ -0,0 +1,211 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mtrrun/internal/model"
+	"github.com/mtrrun/internal/relabel"
+)
+
+func newTestAgent(t *testing.T) *Agent {
+	a, err := New(&Config{Host: "127.0.0.1:1"})
+	require.NoError(t, err)
+
+	c := NewCounter("Requests", "count of requests")
+	c.Add(7)
+	a.Track(c)
+
+	g := NewGauge("Heap/Alloc", "")
+	g.Set(1.5)
+	a.Track(g)
+
+	nan := NewGauge("Broken", "")
+	nan.Set(math.NaN())
+	a.Track(nan)
+
+	return a
+}
+
+func TestHandlerPrometheus(t *testing.T) {
+	a := newTestAgent(t)
+
+	rec := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, contentTypePrometheus, rec.Header().Get(contentTypeHeader))
+	require.Equal(t, "# TYPE Broken gauge\n"+
+		"Broken NaN\n"+
+		"# TYPE Heap_Alloc gauge\n"+
+		"Heap_Alloc 1.5\n"+
+		"# HELP Requests count of requests\n"+
+		"# TYPE Requests counter\n"+
+		"Requests 7\n", rec.Body.String())
+}
+
+func TestHandlerOpenMetrics(t *testing.T) {
+	a := newTestAgent(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set(acceptHeader, "application/openmetrics-text; version=1.0.0")
+
+	rec := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rec, req)
+
+	require.Equal(t, contentTypeOpenMetrics, rec.Header().Get(contentTypeHeader))
+	require.Contains(t, rec.Body.String(), "# TYPE Requests counter\nRequests_total 7\n")
+	require.Contains(t, rec.Body.String(), "\n# EOF\n")
+}
+
+func TestHandlerJSON(t *testing.T) {
+	a := newTestAgent(t)
+
+	rec := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/json", nil))
+
+	var list []model.Metrics
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+
+	// Not finite gauge has no representation in JSON
+	require.Len(t, list, 2)
+	require.Equal(t, "Heap/Alloc", list[0].ID)
+	require.Equal(t, 1.5, *list[0].Value)
+	require.Equal(t, "Requests", list[1].ID)
+	require.Equal(t, int64(7), *list[1].Delta)
+
+	// Counters are cumulative, they are not changed by pull
+	rec = httptest.NewRecorder()
+	a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/json", nil))
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	require.Equal(t, int64(7), *list[1].Delta)
+}
+
+func TestAgentPullOnly(t *testing.T) {
+	_, err := New(&Config{DisablePush: true})
+	require.Error(t, err)
+}
+
+func TestHandlerRelabel(t *testing.T) {
+	a, err := New(&Config{
+		Host:          "127.0.0.1:1",
+		ListenAddress: "127.0.0.1:0",
+		DisablePush:   true,
+		Relabel: &relabel.Config{
+			Rules: []relabel.Rule{
+				{Action: relabel.ActionDrop, Regex: "Random.*"},
+				{Action: relabel.ActionReplace, Regex: "Heap/(.*)", Replacement: "heap_${1}"},
+			},
+			Prefix: "app_",
+			Labels: map[string]string{"host": "a", "env": `pr"od`},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, name := range []string{"Heap/Alloc", "RandomValue"} {
+		g := NewGauge(name, "")
+		g.Set(1)
+		a.Track(g)
+	}
+
+	rec := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, "# TYPE app_heap_Alloc gauge\n"+
+		`app_heap_Alloc{env="pr\"od",host="a"} 1`+"\n", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/json", nil))
+
+	var list []model.Metrics
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	require.Len(t, list, 1)
+	require.Equal(t, "app_heap_Alloc", list[0].ID)
+	require.Equal(t, map[string]string{"host": "a", "env": `pr"od`}, list[0].Labels)
+
+	_, err = New(&Config{Relabel: &relabel.Config{Rules: []relabel.Rule{{Action: "rename", Regex: ".*"}}}})
+	require.Error(t, err)
+}
+
+func TestReportLabels(t *testing.T) {
+	rec := newRecorder(t, http.StatusOK)
+
+	a, err := New(&Config{
+		Host:               rec.host(),
+		PollInterval:       time.Hour,
+		DisableCompression: true,
+		Relabel: &relabel.Config{
+			Prefix: "app_",
+			Labels: map[string]string{"host": "a", "env": "prod"},
+		},
+	})
+	require.NoError(t, err)
+
+	g := NewGauge("Alloc", "")
+	g.Set(1)
+	a.Track(g)
+
+	c := NewCounter("Requests", "")
+	c.Add(2)
+	a.Track(c)
+
+	require.NoError(t, a.report(context.Background()))
+
+	c.Add(3)
+
+	require.NoError(t, a.report(context.Background()))
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	// Server keys metrics only by name, so labels are in names of series
+	ids := make([]string, 0, len(rec.received))
+	deltas := make([]int64, 0, len(rec.received))
+
+	for _, m := range rec.received {
+		ids = append(ids, m.ID)
+
+		if m.Delta != nil {
+			deltas = append(deltas, *m.Delta)
+		}
+
+		require.Empty(t, m.Labels)
+	}
+
+	require.ElementsMatch(t, []string{"app_Alloc;env=prod;host=a", "app_Requests;env=prod;host=a", "app_Requests;env=prod;host=a"}, ids)
+	require.Equal(t, []int64{2, 3}, deltas)
+}
+
+func TestStatusesCollision(t *testing.T) {
+	a, err := New(&Config{
+		Host: "127.0.0.1:1",
+		Relabel: &relabel.Config{
+			Rules: []relabel.Rule{
+				{Action: relabel.ActionReplace, Regex: "(a|b|c)_load", Replacement: "load"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for i, name := range []string{"c_load", "a_load", "b_load"} {
+		g := NewGauge(name, "")
+		g.Set(float64(i))
+		a.Track(g)
+	}
+
+	// Metric with the least original name is kept every time
+	for i := 0; i < 20; i++ {
+		s := a.statuses(false)
+		require.Len(t, s, 1)
+		require.Equal(t, "load", s[0].Name)
+		require.Equal(t, 1.0, s[0].Value.Float64())
+	}
+
+	require.Len(t, a.collisions, 2)
+}
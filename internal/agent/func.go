@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Max duration of callback. If it is expired status of metrics
+// gets previous value and callback continues in background
+const funcTimeout = 100 * time.Millisecond
+
+// GaugeFunc is gauge with value returned by callback.
+// Callback is called when status of metrics is read, e.g. before report
+type GaugeFunc interface {
+	Metric
+}
+
+// CounterFunc is counter with cumulative value returned by callback.
+// Callback is called when status of metrics is read, e.g. before report.
+// Value which is less than previous is treated as reset of source
+type CounterFunc interface {
+	Metric
+}
+
+// callback calling function with timeout and keeping last value.
+// Only one call is running at the same time
+type callback struct {
+	mu      sync.Mutex
+	running bool
+	last    Value
+	d       *Description
+	f       func() Value
+}
+
+// call returning value of function or last value if function is slow or panics
+func (c *callback) call() Value {
+	return c.callBefore(time.Now().Add(funcTimeout))
+}
+
+// callBefore is call which waits for function until deadline
+func (c *callback) callBefore(deadline time.Time) Value {
+	c.mu.Lock()
+	if c.running {
+		last := c.last
+		c.mu.Unlock()
+
+		return last
+	}
+	c.running = true
+	c.mu.Unlock()
+
+	done := make(chan Value, 1)
+
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("callback of metric %s panics: %v\n", c.d.Name, p)
+
+				c.mu.Lock()
+				done <- c.last
+				c.mu.Unlock()
+			}
+
+			c.mu.Lock()
+			c.running = false
+			c.mu.Unlock()
+		}()
+
+		v := c.f()
+
+		c.mu.Lock()
+		c.last = v
+		c.mu.Unlock()
+
+		done <- v
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case v := <-done:
+		return v
+	case <-timer.C:
+		log.Printf("callback of metric %s is timed out, previous value is used\n", c.d.Name)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		return c.last
+	}
+}
+
+// funcMetric is metric with value returned by callback, i.e. GaugeFunc or CounterFunc
+type funcMetric interface {
+	Metric
+	callBefore(deadline time.Time) Value
+}
+
+// Implementing GaugeFunc interface
+type gaugeFunc struct {
+	callback
+}
+
+func (g *gaugeFunc) Desc() Description {
+	return *g.d
+}
+
+// Value returning result of callback
+func (g *gaugeFunc) Value() Value {
+	return g.call()
+}
+
+// Implementing CounterFunc interface
+type counterFunc struct {
+	callback
+}
+
+func (c *counterFunc) Desc() Description {
+	return *c.d
+}
+
+// Value returning result of callback
+func (c *counterFunc) Value() Value {
+	return c.call()
+}
+
+func NewGaugeFunc(name string, help string, f func() float64) GaugeFunc {
+	return &gaugeFunc{
+		callback: callback{
+			last: Float64Value(0),
+			d: &Description{
+				Name: name,
+				Help: help,
+			},
+			f: func() Value {
+				return Float64Value(f())
+			},
+		},
+	}
+}
+
+func NewCounterFunc(name string, help string, f func() int64) CounterFunc {
+	return &counterFunc{
+		callback: callback{
+			last: Int64Value(0),
+			d: &Description{
+				Name: name,
+				Help: help,
+			},
+			f: func() Value {
+				return Int64Value(f())
+			},
+		},
+	}
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGaugeFunc(t *testing.T) {
+	queue := []int{1, 2, 3}
+
+	g := NewGaugeFunc("QueueLength", "", func() float64 {
+		return float64(len(queue))
+	})
+
+	tr := NewTracker()
+	tr.Track(g)
+
+	require.Equal(t, []Status{{Name: "QueueLength", MetricType: gaugeType, Value: Float64Value(3)}}, tr.Status())
+
+	// Value is evaluated lazily
+	queue = queue[:1]
+	require.Equal(t, float64(1), tr.Status()[0].Value.Float64())
+}
+
+func TestCounterFunc(t *testing.T) {
+	var total int64 = 10
+
+	c := NewCounterFunc("Total", "", func() int64 {
+		return total
+	})
+
+	require.Equal(t, counterType, getMetricType(c))
+	require.Equal(t, int64(10), c.Value().Int64())
+}
+
+func TestFuncTimeoutAndPanic(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var calls int32
+
+	g := NewGaugeFunc("Slow", "", func() float64 {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return 5
+		}
+
+		<-release
+
+		return 10
+	})
+
+	require.Equal(t, float64(5), g.Value().Float64())
+
+	// Slow callback doesn't block, previous value is returned
+	start := time.Now()
+	require.Equal(t, float64(5), g.Value().Float64())
+	require.Less(t, time.Since(start), time.Second)
+
+	// Callback which is still running is not called again
+	require.Equal(t, float64(5), g.Value().Float64())
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	p := NewGaugeFunc("Panic", "", func() float64 {
+		panic("boom")
+	})
+
+	require.Equal(t, float64(0), p.Value().Float64())
+}
+
+func TestStatusSlowFuncs(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	tr := NewTracker()
+
+	for _, name := range []string{"Slow1", "Slow2", "Slow3", "Slow4", "Slow5"} {
+		tr.Track(NewGaugeFunc(name, "", func() float64 {
+			<-release
+
+			return 1
+		}))
+	}
+
+	// Callbacks have one deadline, slow callbacks don't delay status one after another
+	start := time.Now()
+	require.Len(t, tr.Status(), 5)
+	require.Less(t, time.Since(start), 3*funcTimeout)
+}
@@ -1,80 +1,62 @@
 package agent
 
 import (
-	"fmt"
-	"sync"
+	"math"
+	"sync/atomic"
 )
 
-// Implementing Gauge interface
+// Implementing Gauge interface without locks.
+// Value is stored as bits of float64, so it is changed with CAS loop
 type gauge struct {
-	mu  sync.RWMutex
-	val float64
-	d   *Description
+	// Must be first field for 64-bit alignment of atomic operations on 32-bit platforms
+	bits uint64
+	d    *Description
 }
 
+// Desc returning description. It is immutable, so lock is not needed
 func (g *gauge) Desc() Description {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-
 	return *g.d
 }
 
 // Set sets the Gauge to an arbitrary value.
 func (g *gauge) Set(val float64) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	g.val = val
+	atomic.StoreUint64(&g.bits, math.Float64bits(val))
 }
 
 // Inc increments the Gauge by 1. Use Add to increment it by arbitrary values
 func (g *gauge) Inc() {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	g.val++
+	g.Add(1)
 }
 
 // Dec decrements the Gauge by 1. Use Sub to decrement it by arbitrary values
 func (g *gauge) Dec() {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	g.val--
+	g.Add(-1)
 }
 
 // Add adds the given value to the Gauge
 func (g *gauge) Add(val float64) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		next := math.Float64bits(math.Float64frombits(old) + val)
 
-	g.val += val
+		if atomic.CompareAndSwapUint64(&g.bits, old, next) {
+			return
+		}
+	}
 }
 
 // Sub subtracts the given value from the Gauge
 func (g *gauge) Sub(val float64) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	g.val -= val
+	g.Add(-val)
 }
 
-// GetValue returned value
-func (g *gauge) GetValue() string {
-	var val float64
-
-	g.mu.Lock()
-
-	val = g.val
-
-	g.mu.Unlock()
-
-	return fmt.Sprintf("%.2f", val)
+// Value returning actual value
+func (g *gauge) Value() Value {
+	return Float64Value(math.Float64frombits(atomic.LoadUint64(&g.bits)))
 }
 
 func NewGauge(name string, help string) Gauge {
 	return &gauge{
-		val: 0,
 		d: &Description{
 			Name: name,
 			Help: help,
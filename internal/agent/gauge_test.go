@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -16,3 +17,91 @@ func TestNewGauge(t *testing.T) {
 	require.Equal(t, name, d.Name)
 	require.Equal(t, help, d.Help)
 }
+
+func TestGaugeConcurrentAdd(t *testing.T) {
+	g := NewGauge("test", "")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				g.Add(0.5)
+				g.Inc()
+				g.Dec()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.Equal(t, float64(4000), g.Value().Float64())
+
+	g.Set(-1.5)
+	g.Sub(1)
+	require.Equal(t, -2.5, g.Value().Float64())
+}
+
+// mutexGauge is previous implementation of gauge, it is kept for comparison in benchmarks
+type mutexGauge struct {
+	mu  sync.Mutex
+	val float64
+}
+
+func (g *mutexGauge) Add(val float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.val += val
+}
+
+func (g *mutexGauge) Set(val float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.val = val
+}
+
+func BenchmarkGaugeAdd(b *testing.B) {
+	g := NewGauge("test", "")
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.Add(1)
+		}
+	})
+}
+
+func BenchmarkGaugeAddMutex(b *testing.B) {
+	g := &mutexGauge{}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.Add(1)
+		}
+	})
+}
+
+func BenchmarkGaugeSet(b *testing.B) {
+	g := NewGauge("test", "")
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.Set(1)
+		}
+	})
+}
+
+func BenchmarkGaugeSetMutex(b *testing.B) {
+	g := &mutexGauge{}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.Set(1)
+		}
+	})
+}
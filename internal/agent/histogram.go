@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"math"
+	"sync"
+)
+
+// Quantiles of histogram which are reported as gauges <name>_p50, <name>_p90, <name>_p99.
+// Count of observations is reported as counter <name>_count
+var histogramQuantiles = []struct {
+	suffix string
+	q      float64
+}{
+	{"_p50", 0.5},
+	{"_p90", 0.9},
+	{"_p99", 0.99},
+}
+
+const histogramCountSuffix = "_count"
+
+// Implementing Histogram interface
+type histogram struct {
+	mu sync.RWMutex
+
+	// Boundaries of buckets, len(buckets) == len(counts)+1.
+	// Boundaries could be infinite
+	buckets []float64
+	counts  []uint64
+	d       *Description
+}
+
+func (h *histogram) Desc() Description {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return *h.d
+}
+
+// Set replacing distribution. Bucket i contains values in [buckets[i], buckets[i+1])
+func (h *histogram) Set(counts []uint64, buckets []float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(buckets) != len(counts)+1 {
+		return
+	}
+
+	h.counts = append(h.counts[:0], counts...)
+	h.buckets = append(h.buckets[:0], buckets...)
+}
+
+// Count returning count of observations
+func (h *histogram) Count() uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var total uint64
+
+	for _, c := range h.counts {
+		total += c
+	}
+
+	return total
+}
+
+// Quantile returning estimation of quantile q with linear interpolation inside bucket
+func (h *histogram) Quantile(q float64) float64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var total uint64
+
+	for _, c := range h.counts {
+		total += c
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	rank := q * float64(total)
+
+	var cum float64
+
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+
+		prev := cum
+		cum += float64(c)
+
+		if cum < rank {
+			continue
+		}
+
+		lo, hi := h.buckets[i], h.buckets[i+1]
+
+		// Infinite boundaries don't allow interpolation
+		switch {
+		case math.IsInf(lo, -1) && math.IsInf(hi, 1):
+			return 0
+		case math.IsInf(lo, -1):
+			return hi
+		case math.IsInf(hi, 1):
+			return lo
+		}
+
+		return lo + (hi-lo)*(rank-prev)/float64(c)
+	}
+
+	return h.buckets[len(h.buckets)-1]
+}
+
+// Value returning median
+func (h *histogram) Value() Value {
+	return Float64Value(h.Quantile(0.5))
+}
+
+// histogramStatus returning quantiles and count of histogram as gauges and counter
+func histogramStatus(name string, h Histogram) []Status {
+	result := make([]Status, 0, len(histogramQuantiles)+1)
+	help := h.Desc().Help
+
+	for _, q := range histogramQuantiles {
+		result = append(result, Status{
+			Name:       name + q.suffix,
+			MetricType: gaugeType,
+			Value:      Float64Value(h.Quantile(q.q)),
+			Help:       help,
+		})
+	}
+
+	return append(result, Status{
+		Name:       name + histogramCountSuffix,
+		MetricType: counterType,
+		Value:      Uint64Value(h.Count()),
+		Help:       help,
+	})
+}
+
+func NewHistogram(name string, help string) Histogram {
+	return &histogram{
+		d: &Description{
+			Name: name,
+			Help: help,
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package agent
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	h := NewHistogram("test", "")
+	require.Equal(t, float64(0), h.Quantile(0.5))
+
+	h.Set([]uint64{0, 10, 10, 0}, []float64{math.Inf(-1), 0, 10, 20, math.Inf(1)})
+
+	require.Equal(t, uint64(20), h.Count())
+	require.InDelta(t, 10, h.Quantile(0.5), 1e-9)
+	require.InDelta(t, 18, h.Quantile(0.9), 1e-9)
+
+	// Infinite boundary
+	h.Set([]uint64{1, 1}, []float64{0, 1, math.Inf(1)})
+	require.Equal(t, float64(1), h.Quantile(0.99))
+}
+
+func TestTrackerExpandsHistogram(t *testing.T) {
+	tr := NewTracker()
+
+	h := NewHistogram("pauses", "")
+	h.Set([]uint64{4}, []float64{0, 1})
+	tr.Track(h)
+
+	got := make(map[string]Status)
+	for _, s := range tr.Status() {
+		got[s.Name] = s
+	}
+
+	require.Len(t, got, 4)
+	require.Equal(t, Status{Name: "pauses_count", MetricType: counterType, Value: Uint64Value(4)}, got["pauses_count"])
+	require.Equal(t, gaugeType, got["pauses_p50"].MetricType)
+	require.Equal(t, 0.5, got["pauses_p50"].Value.Float64())
+}
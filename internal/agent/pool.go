@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Task for worker of pool. Result of do is written to result
+type task struct {
+	ctx    context.Context
+	do     func(ctx context.Context) error
+	result chan<- error
+}
+
+// pool is long-lived set of workers for sending requests.
+// Tasks are fed through bounded queue, so producer is blocked when queue is full
+type pool struct {
+	tasks   chan task
+	limiter *tokenBucket
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// newPool constructor for pool. Workers are started immediately.
+// Tasks which wait for limiter when their context is cancelled fail with error of context
+func newPool(workers, queueSize int, limiter *tokenBucket) *pool {
+	p := &pool{
+		tasks:   make(chan task, queueSize),
+		limiter: limiter,
+	}
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+
+		go p.work()
+	}
+
+	return p
+}
+
+func (p *pool) work() {
+	defer p.wg.Done()
+
+	for t := range p.tasks {
+		if err := p.limiter.Wait(t.ctx); err != nil {
+			t.result <- err
+
+			continue
+		}
+
+		t.result <- t.do(t.ctx)
+	}
+}
+
+// Submit adding task to queue. It blocks while queue is full
+func (p *pool) Submit(ctx context.Context, do func(ctx context.Context) error, result chan<- error) {
+	p.tasks <- task{ctx: ctx, do: do, result: result}
+}
+
+// Stop waiting for tasks in queue and stopping workers.
+// Submit must not be called after Stop
+func (p *pool) Stop() {
+	p.once.Do(func() {
+		close(p.tasks)
+		p.wg.Wait()
+	})
+}
+
+// tokenBucket limiting rate of requests. Bucket has burst tokens and
+// gets rate tokens per second. Nil bucket doesn't limit anything
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket constructor for tokenBucket. Returns nil if rate is not positive.
+// If burst is empty that will be use default value - 1.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait taking one token. Returns error of context if it was cancelled while waiting
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	if b == nil {
+		return ctx.Err()
+	}
+
+	for {
+		d, ok := b.reserve()
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(d)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve taking token if it is available, else returning time until next token
+func (b *tokenBucket) reserve() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+
+		return 0, true
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second)), false
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolDrainsQueueOnStop(t *testing.T) {
+	p := newPool(2, 10, nil)
+
+	var (
+		done    int32
+		running int32
+		peak    int32
+	)
+
+	results := make(chan error, 10)
+
+	for i := 0; i < 10; i++ {
+		p.Submit(context.Background(), func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+
+			for {
+				old := atomic.LoadInt32(&peak)
+				if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&done, 1)
+
+			return nil
+		}, results)
+	}
+
+	p.Stop()
+
+	require.Equal(t, int32(10), atomic.LoadInt32(&done))
+	require.LessOrEqual(t, atomic.LoadInt32(&peak), int32(2))
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, <-results)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	require.Nil(t, newTokenBucket(0, 10))
+
+	b := newTokenBucket(100, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	start := time.Now()
+
+	// Two tokens are available at once, next three take about 10ms each
+	for i := 0; i < 5; i++ {
+		require.NoError(t, b.Wait(ctx))
+	}
+
+	require.GreaterOrEqual(t, time.Since(start), 25*time.Millisecond)
+
+	// Waiting is interrupted by cancel
+	b = newTokenBucket(0.001, 1)
+	require.NoError(t, b.Wait(ctx))
+
+	cancel()
+	require.ErrorIs(t, b.Wait(ctx), context.Canceled)
+}
+
+func TestPoolFailsCancelledTasks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newPool(1, 1, newTokenBucket(0.001, 1))
+
+	results := make(chan error, 2)
+	do := func(ctx context.Context) error { return nil }
+
+	p.Submit(ctx, do, results)
+	require.NoError(t, <-results)
+
+	// Second task waits for token until cancel
+	p.Submit(ctx, do, results)
+	cancel()
+
+	require.ErrorIs(t, <-results, context.Canceled)
+	p.Stop()
+}
@@ -0,0 +1,454 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mtrrun/internal/model"
+	"github.com/mtrrun/internal/relabel"
+	"github.com/mtrrun/internal/spool"
+)
+
+// route is independent stream of reports. In fan-out mode every destination has its own route,
+// in failover mode one route has all destinations in priority order.
+// Route remembers what was delivered, so every route reports changes and counter
+// differences since its own last report
+type route struct {
+	// Destinations in priority order. Next destination gets metrics which were not delivered to previous
+	dests []*destination
+
+	// Queue on disk for reports which were not delivered. Nil if it is disabled
+	spool *spool.Queue
+
+	// Values of metrics which were delivered or spooled, only changed metrics are reported
+	changes *changes
+
+	// Cumulative values of counters which were delivered or spooled.
+	// Server adds received value to counter, so agent sends only difference
+	sentMu sync.Mutex
+	sent   map[string]int64
+
+	// Static labels are added to names of reported metrics. Nil if there are no labels
+	relabeler *relabel.Relabeler
+
+	// Report in progress, next report of route is skipped until it ends
+	busyMu sync.Mutex
+	busy   bool
+}
+
+func newRoute(dests []*destination, c *changes, relabeler *relabel.Relabeler) *route {
+	return &route{
+		dests:     dests,
+		spool:     dests[0].spool,
+		changes:   c,
+		sent:      make(map[string]int64),
+		relabeler: relabeler,
+	}
+}
+
+// name returning names of destinations for logs
+func (r *route) name() string {
+	names := make([]string, 0, len(r.dests))
+
+	for _, d := range r.dests {
+		names = append(names, d.name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+// tryLock marking route as busy. Returns false if previous report is still running
+func (r *route) tryLock() bool {
+	r.busyMu.Lock()
+	defer r.busyMu.Unlock()
+
+	if r.busy {
+		return false
+	}
+
+	r.busy = true
+
+	return true
+}
+
+func (r *route) unlock() {
+	r.busyMu.Lock()
+	r.busy = false
+	r.busyMu.Unlock()
+}
+
+// report sending report with metrics.
+// Counters are sent as difference with last delivered value. If spool is enabled
+// reports which were not delivered are stored on disk and sent before
+// new reports when server becomes available. Else undelivered counters
+// are merged into next report.
+// Only metrics which were changed since last report are sent, see changes.
+func (r *route) report(ctx context.Context, s []Status) error {
+	statuses := r.changes.Filter(s)
+	batch := r.prepare(statuses)
+
+	// Reports must reach server in the same order as they were made
+	if r.spool != nil && !r.replay(ctx) {
+		log.Printf("%s is unavailable, report is stored in spool", r.name())
+
+		if err := r.store(batch); err != nil {
+			return err
+		}
+
+		r.changes.Ack(statuses, nil)
+
+		return nil
+	}
+
+	failed := batch
+
+	for i, d := range r.dests {
+		if i > 0 {
+			log.Printf("%d metrics were not delivered, failover to %s\n", len(failed), d.name)
+		}
+
+		if failed = d.send(ctx, failed); len(failed) == 0 {
+			break
+		}
+	}
+
+	if len(failed) == 0 {
+		r.changes.Ack(statuses, nil)
+
+		return nil
+	}
+
+	if r.spool != nil {
+		if err := r.store(failed); err != nil {
+			r.changes.Ack(statuses, metricIDs(failed))
+
+			return err
+		}
+
+		r.changes.Ack(statuses, nil)
+
+		return nil
+	}
+
+	r.rollback(failed)
+	r.changes.Ack(statuses, metricIDs(failed))
+
+	return fmt.Errorf("%d of %d metrics were not delivered", len(failed), len(batch))
+}
+
+// stored reporting whether spool has reports which were not delivered
+func (r *route) stored() bool {
+	if r.spool == nil {
+		return false
+	}
+
+	_, ok, err := r.spool.Peek()
+
+	return err != nil || ok
+}
+
+// metricIDs returning set of names of metrics
+func metricIDs(batch []model.Metrics) map[string]struct{} {
+	result := make(map[string]struct{}, len(batch))
+
+	for _, m := range batch {
+		result[m.ID] = struct{}{}
+	}
+
+	return result
+}
+
+// prepare mapping metrics state to data transfer objects with static labels in names and
+// replacing values of counters with difference from last sent value.
+// Counter which decreased was reset, e.g. source of CounterFunc was restarted,
+// so its whole value is sent, else server would subtract difference
+func (r *route) prepare(s []Status) []model.Metrics {
+	r.sentMu.Lock()
+	defer r.sentMu.Unlock()
+
+	batch := make([]model.Metrics, 0, len(s))
+
+	for i := range s {
+		m, err := newMetrics(s[i])
+		if err != nil {
+			log.Printf("unable to encode metric %s: %s\n", s[i].Name, err)
+
+			continue
+		}
+
+		m.ID = r.relabeler.Series(m.ID)
+
+		if m.Delta != nil {
+			total := *m.Delta
+			delta := total - r.sent[m.ID]
+
+			if delta < 0 {
+				delta = total
+			}
+
+			r.sent[m.ID] = total
+			m.Delta = &delta
+		}
+
+		batch = append(batch, m)
+	}
+
+	return batch
+}
+
+// rollback returning differences of undelivered counters, so they are sent in next report
+func (r *route) rollback(failed []model.Metrics) {
+	r.sentMu.Lock()
+	defer r.sentMu.Unlock()
+
+	for _, m := range failed {
+		if m.Delta != nil {
+			r.sent[m.ID] -= *m.Delta
+		}
+	}
+}
+
+// store appending metrics to spool. Metrics are rolled back if they could not be stored
+func (r *route) store(batch []model.Metrics) error {
+	body, err := json.Marshal(batch)
+	if err == nil {
+		err = r.spool.Append(body)
+	}
+
+	if err != nil {
+		r.rollback(batch)
+
+		return fmt.Errorf("unable to store report in spool: %w", err)
+	}
+
+	return nil
+}
+
+// replay sending stored reports in order, every report to the first destination which accepts it.
+// Returns true if spool is empty
+func (r *route) replay(ctx context.Context) bool {
+	for {
+		body, ok, err := r.spool.Peek()
+		if err != nil {
+			log.Printf("unable to read report from spool: %s\n", err)
+
+			return false
+		}
+
+		if !ok {
+			return true
+		}
+
+		if !r.replayOne(ctx, body) {
+			return false
+		}
+
+		if err = r.spool.Ack(); err != nil {
+			log.Printf("unable to remove report from spool: %s\n", err)
+
+			return false
+		}
+	}
+}
+
+// replayOne sending stored report. Returns false if no destination is available.
+// Report is dropped only if server rejected its content, other errors keep it in spool
+func (r *route) replayOne(ctx context.Context, body []byte) bool {
+	for _, d := range r.dests {
+		if err := d.limiter.Wait(ctx); err != nil {
+			return false
+		}
+
+		err := d.client.DoRequest(ctx, http.MethodPost, d.url("/updates/"), map[string]string{contentTypeHeader: defaultContentType}, body)
+		if err == nil {
+			return true
+		}
+
+		if isRejected(err) {
+			// Server will never accept this report
+			log.Printf("stored report was rejected by %s and dropped: %s\n", d.name, err)
+
+			return true
+		}
+
+		log.Printf("unable to send stored report to %s: %s\n", d.name, err)
+	}
+
+	return false
+}
+
+// report sending report to every route concurrently and waiting for all of them
+func (a *Agent) report(ctx context.Context) error {
+	current := a.container.Status()
+	errs := make([]error, len(a.routes))
+
+	var wg sync.WaitGroup
+
+	for i, r := range a.routes {
+		statuses := a.routeStatuses(current, i, true)
+
+		wg.Add(1)
+
+		go func(i int, r *route) {
+			defer wg.Done()
+
+			if err := r.report(ctx, statuses); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", r.name(), err)
+			}
+		}(i, r)
+	}
+
+	wg.Wait()
+
+	return joinErrors(errs)
+}
+
+// dispatch starting report of every route which is not busy with previous report.
+// Slow destination doesn't delay reports to others, its reports are skipped and
+// skipped changes are sent with next report. Window of aggregation of skipped route
+// is not reset, so its next report has samples of skipped interval too
+func (a *Agent) dispatch(ctx context.Context, wg *sync.WaitGroup) {
+	current := a.container.Status()
+
+	for i, r := range a.routes {
+		if !r.tryLock() {
+			log.Printf("previous report to %s is still running, skipping\n", r.name())
+
+			continue
+		}
+
+		statuses := a.routeStatuses(current, i, true)
+
+		wg.Add(1)
+
+		go func(r *route) {
+			defer wg.Done()
+			defer r.unlock()
+
+			if err := r.report(ctx, statuses); err != nil {
+				log.Printf("report to %s failed: %s\n", r.name(), err)
+			}
+		}(r)
+	}
+}
+
+// joinErrors combining not nil errors into one error. Returns nil if there are no errors
+func joinErrors(errs []error) error {
+	msgs := make([]string, 0, len(errs))
+
+	var first error
+
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if first == nil {
+			first = err
+		}
+
+		msgs = append(msgs, err.Error())
+	}
+
+	switch len(msgs) {
+	case 0:
+		return nil
+	case 1:
+		return first
+	default:
+		return errors.New(strings.Join(msgs, "; "))
+	}
+}
+
+// statuses returning state of metrics for pull mode, see routeStatuses.
+// Aggregated gauges are taken from window of the first route
+func (a *Agent) statuses(reset bool) []Status {
+	return a.routeStatuses(a.container.Status(), 0, reset)
+}
+
+// routeStatuses returning current state of metrics with aggregated gauges of route and names after relabeling.
+// If metrics of the same type get the same name, metric with the least original name is kept,
+// so the same metric is reported every time. If reset is true the next window of aggregation of route is started
+func (a *Agent) routeStatuses(current []Status, route int, reset bool) []Status {
+	s := a.aggregator.Append(current, route, reset)
+
+	if a.relabeler == nil {
+		return s
+	}
+
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Name < s[j].Name
+	})
+
+	result := make([]Status, 0, len(s))
+	seen := make(map[string]string, len(s))
+
+	for _, st := range s {
+		name, ok := a.relabeler.Apply(st.Name)
+		if !ok {
+			continue
+		}
+
+		original := st.Name
+		st.Name = name
+		key := statusKey(st)
+
+		if kept, ok := seen[key]; ok {
+			a.logCollision(original, kept, name)
+
+			continue
+		}
+
+		seen[key] = original
+		result = append(result, st)
+	}
+
+	return result
+}
+
+// logCollision logging once that metric is dropped, because other metric got the same name after relabeling
+func (a *Agent) logCollision(dropped, kept, name string) {
+	a.collisionsMu.Lock()
+	defer a.collisionsMu.Unlock()
+
+	if _, ok := a.collisions[dropped]; ok {
+		return
+	}
+
+	a.collisions[dropped] = struct{}{}
+
+	log.Printf("metric %s is dropped, metric %s has the same name %s after relabeling\n", dropped, kept, name)
+}
+
+// newMetrics mapping metric state to data transfer object for server
+func newMetrics(s Status) (model.Metrics, error) {
+	m := model.Metrics{
+		ID:    s.Name,
+		MType: s.MetricType,
+	}
+
+	switch s.MetricType {
+	case gaugeType:
+		// Server accepts only finite values and JSON has no representation for others
+		if !s.Value.IsFinite() {
+			return m, fmt.Errorf("value %s is not finite", s.Value)
+		}
+
+		v := s.Value.Float64()
+		m.Value = &v
+	case counterType:
+		v := s.Value.Int64()
+		m.Delta = &v
+	default:
+		return m, fmt.Errorf("unsupported metric type %q", s.MetricType)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,191 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mtrrun/internal/model"
+)
+
+func TestRoutePrepareCounterReset(t *testing.T) {
+	r := &route{sent: make(map[string]int64)}
+
+	var total int64 = 10
+
+	c := NewCounterFunc("Requests", "", func() int64 {
+		return total
+	})
+
+	delta := func() int64 {
+		batch := r.prepare(appendStatus(nil, "Requests", c))
+		require.Len(t, batch, 1)
+
+		return *batch[0].Delta
+	}
+
+	require.Equal(t, int64(10), delta())
+
+	total = 15
+	require.Equal(t, int64(5), delta())
+
+	// Source was restarted, value since reset is sent instead of negative difference
+	total = 3
+	require.Equal(t, int64(3), delta())
+
+	total = 7
+	require.Equal(t, int64(4), delta())
+}
+
+// replyClient returning errors for requests in order
+type replyClient struct {
+	errs []error
+}
+
+func (c *replyClient) DoRequest(_ context.Context, _, _ string, _ map[string]string, _ []byte) error {
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+
+	return err
+}
+
+func (c *replyClient) Shutdown() {}
+
+func TestRouteReplayOne(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		sent bool
+	}{
+		{name: "delivered", sent: true},
+		{name: "invalid metrics", err: &customHTTPError{Status: http.StatusBadRequest}, sent: true},
+		{name: "too large", err: &customHTTPError{Status: http.StatusRequestEntityTooLarge}, sent: true},
+		{name: "unauthorized", err: &customHTTPError{Status: http.StatusUnauthorized}},
+		{name: "forbidden", err: &customHTTPError{Status: http.StatusForbidden}},
+		{name: "not found", err: &customHTTPError{Status: http.StatusNotFound}},
+		{name: "unavailable", err: &customHTTPError{Status: http.StatusServiceUnavailable}},
+		{name: "invalid response signature", err: errInvalidSignature},
+		{name: "invalid response body", err: errors.New("gzip: invalid header")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &replyClient{errs: []error{tt.err}}
+			r := &route{dests: []*destination{{name: "main", host: "127.0.0.1:8080", scheme: "http", client: c}}}
+
+			// Report which was not sent stays in spool
+			require.Equal(t, tt.sent, r.replayOne(context.Background(), []byte("[]")))
+		})
+	}
+
+	// Report is sent to the next destination if the first one is unavailable
+	r := &route{dests: []*destination{
+		{name: "main", client: &replyClient{errs: []error{errInvalidSignature}}},
+		{name: "reserve", client: &replyClient{errs: []error{nil}}},
+	}}
+
+	require.True(t, r.replayOne(context.Background(), []byte("[]")))
+}
+
+// spoolRequest request which was accepted by server
+type spoolRequest struct {
+	path    string
+	metrics []model.Metrics
+}
+
+func TestAgentSpoolReplay(t *testing.T) {
+	var (
+		down     int32 = 1
+		mu       sync.Mutex
+		accepted []spoolRequest
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var list []model.Metrics
+
+		// Stored reports are sent as list, new reports by one metric
+		if bytes.HasPrefix(body, []byte("[")) {
+			require.NoError(t, json.Unmarshal(body, &list))
+		} else {
+			var m model.Metrics
+			require.NoError(t, json.Unmarshal(body, &m))
+
+			list = append(list, m)
+		}
+
+		mu.Lock()
+		accepted = append(accepted, spoolRequest{path: r.URL.Path, metrics: list})
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	a, err := New(&Config{
+		Host:               strings.TrimPrefix(srv.URL, "http://"),
+		PollInterval:       time.Hour,
+		Retries:            -1,
+		DisableCompression: true,
+		SpoolDir:           t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	defer a.close()
+
+	c := NewCounter("Requests", "")
+	a.Track(c)
+
+	// Server is unavailable, reports are stored instead of being rolled back into next report
+	c.Add(2)
+	require.NoError(t, a.report(context.Background()))
+
+	c.Add(3)
+	require.NoError(t, a.report(context.Background()))
+
+	atomic.StoreInt32(&down, 0)
+
+	c.Add(4)
+	require.NoError(t, a.report(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Stored reports reach server in order before the new one
+	require.Len(t, accepted, 3)
+	require.Equal(t, []string{"/updates/", "/updates/", "/update/"}, []string{accepted[0].path, accepted[1].path, accepted[2].path})
+
+	var (
+		deltas []int64
+		total  int64
+	)
+
+	for _, req := range accepted {
+		require.Len(t, req.metrics, 1)
+		require.Equal(t, "Requests", req.metrics[0].ID)
+
+		deltas = append(deltas, *req.metrics[0].Delta)
+		total += *req.metrics[0].Delta
+	}
+
+	// Every increment is counted once
+	require.Equal(t, []int64{2, 3, 4}, deltas)
+	require.Equal(t, int64(9), total)
+}
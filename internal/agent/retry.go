@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultRetryInitialInterval = 100 * time.Millisecond
+	defaultRetryMaxInterval     = 5 * time.Second
+)
+
+// RetryPolicy configuration of retries with exponential backoff and full jitter.
+// Delay before retry n is random value in [0, min(MaxInterval, InitialInterval * 2^n)).
+// If server sent header "Retry-After" its value is used as delay.
+type RetryPolicy struct {
+	// Max count of retries after first attempt. If MaxRetries is empty requests are not retried
+	MaxRetries int
+
+	// If InitialInterval is empty that will be use default value - 100 milliseconds.
+	InitialInterval time.Duration
+
+	// If MaxInterval is empty that will be use default value - 5 second.
+	MaxInterval time.Duration
+
+	// Max time from first attempt after which request is not retried.
+	// If MaxElapsedTime is empty time is not limited
+	MaxElapsedTime time.Duration
+}
+
+// next returning delay before retry after attempt. Returns false
+// if retry would exceed MaxElapsedTime
+func (p RetryPolicy) next(attempt int, err error, elapsed time.Duration) (time.Duration, bool) {
+	initial, maxInterval := p.InitialInterval, p.MaxInterval
+
+	if initial <= 0 {
+		initial = defaultRetryInitialInterval
+	}
+
+	if maxInterval <= 0 {
+		maxInterval = defaultRetryMaxInterval
+	}
+
+	// Doubling step by step for avoiding overflow on big attempts
+	ceiling := initial
+	for i := 0; i < attempt && ceiling < maxInterval; i++ {
+		ceiling *= 2
+	}
+
+	if ceiling > maxInterval {
+		ceiling = maxInterval
+	}
+
+	wait := time.Duration(rand.Int63n(int64(ceiling) + 1))
+
+	var httpErr *customHTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		wait = httpErr.RetryAfter
+	}
+
+	if p.MaxElapsedTime > 0 && elapsed+wait > p.MaxElapsedTime {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+// isRetriable reports whether request with error could succeed if it is repeated
+func isRetriable(err error) bool {
+	// Request was cancelled by agent, it could succeed in next report
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var httpErr *customHTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Retriable()
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isRejected reports whether server rejected request because of its content, so it will never be accepted.
+// Errors of credentials, e.g. 401 and 403, errors of client and invalid signature of response
+// could be fixed by configuration of agent or server, so they are not rejections
+func isRejected(err error) bool {
+	var httpErr *customHTTPError
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+
+	switch httpErr.Status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter parsing header "Retry-After" with seconds or HTTP date
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		calls    int32
+		ok       bool
+	}{
+		{
+			name:     "retriable statuses",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			calls:    3,
+			ok:       true,
+		},
+		{
+			name:     "validation error fails fast",
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			calls:    1,
+			ok:       false,
+		},
+		{
+			name:     "retries are exhausted",
+			statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+			calls:    4,
+			ok:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			c := NewClient(&ClientConfig{
+				Retry: RetryPolicy{
+					MaxRetries:      3,
+					InitialInterval: time.Millisecond,
+					MaxInterval:     5 * time.Millisecond,
+				},
+			})
+
+			err := c.DoRequest(context.Background(), http.MethodPost, srv.URL, nil, []byte("{}"))
+
+			require.Equal(t, tt.ok, err == nil)
+			require.Equal(t, tt.calls, atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestRetryPolicyNext(t *testing.T) {
+	p := RetryPolicy{
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     time.Second,
+		MaxElapsedTime:  2 * time.Second,
+	}
+
+	for attempt := 0; attempt < 100; attempt++ {
+		wait, ok := p.next(attempt, nil, 0)
+		require.True(t, ok)
+		require.LessOrEqual(t, wait, time.Second)
+	}
+
+	// Retry-After has priority over backoff
+	wait, ok := p.next(0, &customHTTPError{Status: http.StatusTooManyRequests, RetryAfter: 1500 * time.Millisecond}, 0)
+	require.True(t, ok)
+	require.Equal(t, 1500*time.Millisecond, wait)
+
+	// Max elapsed time is exceeded
+	_, ok = p.next(0, &customHTTPError{Status: http.StatusTooManyRequests, RetryAfter: time.Second}, 1500*time.Millisecond)
+	require.False(t, ok)
+
+	require.Equal(t, 120*time.Second, parseRetryAfter("120"))
+	require.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+}
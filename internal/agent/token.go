@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource returning actual bearer token for requests
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// staticToken is token from configuration
+type staticToken string
+
+func (t staticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+// fileToken reads token from file and re-reads it
+// when modification time of file changed, e.g. after rotation
+type fileToken struct {
+	mu sync.Mutex
+
+	path    string
+	token   string
+	modTime time.Time
+}
+
+// NewTokenSource constructor for TokenSource.
+// If path is not empty token is read from file, else token is used as is
+func NewTokenSource(token, path string) TokenSource {
+	if path == "" {
+		return staticToken(token)
+	}
+
+	return &fileToken{
+		path: path,
+	}
+}
+
+func (t *fileToken) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info, err := os.Stat(t.path)
+	if err != nil {
+		// Old token is still valid if file is replaced right now
+		if t.token != "" {
+			return t.token, nil
+		}
+
+		return "", err
+	}
+
+	if t.token != "" && info.ModTime().Equal(t.modTime) {
+		return t.token, nil
+	}
+
+	b, err := os.ReadFile(t.path)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", t.path)
+	}
+
+	t.token = token
+	t.modTime = info.ModTime()
+
+	return t.token, nil
+}
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTokenReread(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("first\n"), 0o600))
+
+	s := NewTokenSource("static", path)
+
+	token, err := s.Token()
+	require.NoError(t, err)
+	require.Equal(t, "first", token)
+
+	// Token is re-read after rotation, modification time is set explicitly
+	// because file system could have coarse timestamps
+	require.NoError(t, os.WriteFile(path, []byte("second\n"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	token, err = s.Token()
+	require.NoError(t, err)
+	require.Equal(t, "second", token)
+
+	// Old token is used while file is replaced
+	require.NoError(t, os.Remove(path))
+
+	token, err = s.Token()
+	require.NoError(t, err)
+	require.Equal(t, "second", token)
+
+	_, err = NewTokenSource("", filepath.Join(t.TempDir(), "missing")).Token()
+	require.Error(t, err)
+}
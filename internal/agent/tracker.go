@@ -2,6 +2,7 @@ package agent
 
 import (
 	"sync"
+	"time"
 )
 
 // tracker is main storage with metrics
@@ -13,7 +14,7 @@ type tracker struct {
 
 	metrics map[string]Metric
 
-	// TODO: histogram, summary
+	// TODO: summary
 }
 
 // Track added metric to list with all metrics
@@ -32,27 +33,83 @@ func (r *tracker) Untrack(met Metric) {
 	delete(r.metrics, met.Desc().Name)
 }
 
-// Status returned information about actual metrics state
+// Status returned information about actual metrics state.
+// Values are read without lock, so callbacks of GaugeFunc and CounterFunc don't block Track
 func (r *tracker) Status() []Status {
 	r.mu.RLock()
-	defer r.mu.RUnlock()
 
-	s := make([]Status, len(r.metrics))
+	names := make([]string, 0, len(r.metrics))
+	metrics := make([]Metric, 0, len(r.metrics))
 
-	count := 0
 	for k, v := range r.metrics {
-		s[count] = Status{
-			Name:       k,
-			MetricType: getMetricType(v),
-			Value:      v.GetValue(),
+		names = append(names, k)
+		metrics = append(metrics, v)
+	}
+
+	r.mu.RUnlock()
+
+	return metricsStatus(names, metrics)
+}
+
+// metricsStatus returning states of metrics with names. Callbacks of GaugeFunc and CounterFunc
+// are called concurrently with one deadline, so slow callbacks delay status by funcTimeout in total
+func metricsStatus(names []string, metrics []Metric) []Status {
+	values := make([]Value, len(metrics))
+	deadline := time.Now().Add(funcTimeout)
+
+	var wg sync.WaitGroup
+
+	for i, m := range metrics {
+		f, ok := m.(funcMetric)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(i int, f funcMetric) {
+			defer wg.Done()
+
+			values[i] = f.callBefore(deadline)
+		}(i, f)
+	}
+
+	wg.Wait()
+
+	s := make([]Status, 0, len(metrics))
+
+	for i, m := range metrics {
+		if _, ok := m.(funcMetric); !ok {
+			s = appendStatus(s, names[i], m)
+
+			continue
 		}
 
-		count++
+		s = append(s, Status{
+			Name:       names[i],
+			MetricType: getMetricType(m),
+			Value:      values[i],
+			Help:       m.Desc().Help,
+		})
 	}
 
 	return s
 }
 
+// appendStatus adding state of metric to s. Histogram has several statuses
+func appendStatus(s []Status, name string, m Metric) []Status {
+	if h, ok := m.(Histogram); ok {
+		return append(s, histogramStatus(name, h)...)
+	}
+
+	return append(s, Status{
+		Name:       name,
+		MetricType: getMetricType(m),
+		Value:      m.Value(),
+		Help:       m.Desc().Help,
+	})
+}
+
 // NewTracker constructor for Tracker
 func NewTracker() Tracker {
 
@@ -60,3 +117,98 @@ func NewTracker() Tracker {
 		metrics: make(map[string]Metric),
 	}
 }
+
+// changes remembering values of metrics which were acknowledged, i.e. delivered
+// to server or stored in spool, so report contains only changed metrics.
+// Every resync interval all metrics are reported, so restarted server gets everything
+type changes struct {
+	mu sync.Mutex
+
+	// Key is type and name of metric, value is acknowledged value
+	acked map[string]Value
+
+	// Metrics which are reported even if they are not changed
+	always map[string]struct{}
+
+	// Change tracking is disabled if resync is not positive
+	resync   time.Duration
+	lastFull time.Time
+}
+
+func newChanges(resync time.Duration, always []string) *changes {
+	c := &changes{
+		acked:  make(map[string]Value),
+		always: make(map[string]struct{}, len(always)),
+		resync: resync,
+	}
+
+	for _, name := range always {
+		c.always[name] = struct{}{}
+	}
+
+	return c
+}
+
+// Filter returning metrics which were changed since they were acknowledged
+func (c *changes) Filter(s []Status) []Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resync <= 0 {
+		return s
+	}
+
+	if now := time.Now(); now.Sub(c.lastFull) >= c.resync {
+		c.lastFull = now
+
+		// Forgetting metrics which are not tracked anymore
+		current := make(map[string]Value, len(s))
+
+		for _, v := range s {
+			k := statusKey(v)
+			if acked, ok := c.acked[k]; ok {
+				current[k] = acked
+			}
+		}
+
+		c.acked = current
+
+		return s
+	}
+
+	result := make([]Status, 0, len(s))
+
+	for _, v := range s {
+		if _, ok := c.always[v.Name]; ok {
+			result = append(result, v)
+
+			continue
+		}
+
+		if acked, ok := c.acked[statusKey(v)]; ok && acked == v.Value {
+			continue
+		}
+
+		result = append(result, v)
+	}
+
+	return result
+}
+
+// Ack remembering values of metrics except metrics with names from failed
+func (c *changes) Ack(s []Status, failed map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, v := range s {
+		if _, ok := failed[v.Name]; ok {
+			continue
+		}
+
+		c.acked[statusKey(v)] = v.Value
+	}
+}
+
+func statusKey(s Status) string {
+	return s.MetricType + "/" + s.Name
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func names(s []Status) []string {
+	result := make([]string, 0, len(s))
+
+	for _, v := range s {
+		result = append(result, v.Name)
+	}
+
+	return result
+}
+
+func TestChangesFilter(t *testing.T) {
+	c := newChanges(time.Hour, []string{"Always"})
+
+	s := []Status{
+		{Name: "Same", MetricType: gaugeType, Value: Float64Value(1)},
+		{Name: "Changed", MetricType: gaugeType, Value: Float64Value(1)},
+		{Name: "Failed", MetricType: counterType, Value: Int64Value(1)},
+		{Name: "Always", MetricType: gaugeType, Value: Float64Value(1)},
+	}
+
+	// First report is full
+	require.Equal(t, names(s), names(c.Filter(s)))
+	c.Ack(s, map[string]struct{}{"Failed": {}})
+
+	s[1].Value = Float64Value(2)
+
+	require.ElementsMatch(t, []string{"Changed", "Failed", "Always"}, names(c.Filter(s)))
+	c.Ack(s, nil)
+
+	require.Equal(t, []string{"Always"}, names(c.Filter(s)))
+}
+
+func TestChangesResync(t *testing.T) {
+	c := newChanges(time.Hour, nil)
+
+	s := []Status{{Name: "Same", MetricType: gaugeType, Value: Float64Value(1)}}
+
+	c.Filter(s)
+	c.Ack(s, nil)
+	require.Empty(t, c.Filter(s))
+
+	// Resync interval is expired
+	c.lastFull = time.Now().Add(-2 * time.Hour)
+	require.Len(t, c.Filter(s), 1)
+
+	// Change tracking is disabled
+	c = newChanges(-1, nil)
+	c.Ack(s, nil)
+	require.Len(t, c.Filter(s), 1)
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"math"
+	"strconv"
+)
+
+// ValueKind type of value of metric
+type ValueKind int
+
+const (
+	KindFloat64 ValueKind = iota
+	KindInt64
+	KindUint64
+)
+
+// Value is typed value of metric. Values are not formatted
+// until they are sent, so no precision is lost inside agent
+type Value struct {
+	kind ValueKind
+	bits uint64
+}
+
+// Float64Value constructor for float64 Value
+func Float64Value(v float64) Value {
+	return Value{kind: KindFloat64, bits: math.Float64bits(v)}
+}
+
+// Int64Value constructor for int64 Value
+func Int64Value(v int64) Value {
+	return Value{kind: KindInt64, bits: uint64(v)}
+}
+
+// Uint64Value constructor for uint64 Value
+func Uint64Value(v uint64) Value {
+	return Value{kind: KindUint64, bits: v}
+}
+
+// Kind returning type of value
+func (v Value) Kind() ValueKind {
+	return v.kind
+}
+
+// Float64 returning value as float64. Integers bigger than 2^53 are rounded
+func (v Value) Float64() float64 {
+	switch v.kind {
+	case KindInt64:
+		return float64(int64(v.bits))
+	case KindUint64:
+		return float64(v.bits)
+	default:
+		return math.Float64frombits(v.bits)
+	}
+}
+
+// Int64 returning value as int64. Float is truncated, uint64 bigger than max int64 is saturated
+func (v Value) Int64() int64 {
+	switch v.kind {
+	case KindInt64:
+		return int64(v.bits)
+	case KindUint64:
+		if v.bits > math.MaxInt64 {
+			return math.MaxInt64
+		}
+
+		return int64(v.bits)
+	default:
+		return int64(math.Float64frombits(v.bits))
+	}
+}
+
+// Uint64 returning value as uint64. Negative values are zero
+func (v Value) Uint64() uint64 {
+	switch v.kind {
+	case KindUint64:
+		return v.bits
+	default:
+		if f := v.Float64(); f > 0 {
+			return uint64(f)
+		}
+
+		return 0
+	}
+}
+
+// IsFinite reports whether value is not NaN or infinity
+func (v Value) IsFinite() bool {
+	if v.kind != KindFloat64 {
+		return true
+	}
+
+	f := math.Float64frombits(v.bits)
+
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// String formatting value for text transport. Floats are formatted with
+// the shortest representation which is parsed back to the same value
+func (v Value) String() string {
+	switch v.kind {
+	case KindInt64:
+		return strconv.FormatInt(int64(v.bits), 10)
+	case KindUint64:
+		return strconv.FormatUint(v.bits, 10)
+	default:
+		return formatFloat(math.Float64frombits(v.bits))
+	}
+}
+
+// formatFloat formatting float with the shortest representation like encoding/json:
+// exponent is used only for very small and very big values
+func formatFloat(f float64) string {
+	format := byte('f')
+
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	return strconv.FormatFloat(f, format, -1, 64)
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueRoundTrip(t *testing.T) {
+	floats := func(v float64) bool {
+		got, err := strconv.ParseFloat(Float64Value(v).String(), 64)
+
+		return err == nil && (got == v || math.IsNaN(v) && math.IsNaN(got))
+	}
+
+	ints := func(v int64) bool {
+		got, err := strconv.ParseInt(Int64Value(v).String(), 10, 64)
+
+		return err == nil && got == v && Int64Value(v).Int64() == v
+	}
+
+	uints := func(v uint64) bool {
+		got, err := strconv.ParseUint(Uint64Value(v).String(), 10, 64)
+
+		return err == nil && got == v && Uint64Value(v).Uint64() == v
+	}
+
+	require.NoError(t, quick.Check(floats, nil))
+	require.NoError(t, quick.Check(ints, nil))
+	require.NoError(t, quick.Check(uints, nil))
+
+	// Small values are not rounded
+	require.Equal(t, "0.0003", Float64Value(0.0003).String())
+	require.Equal(t, "18446744073709551615", Uint64Value(math.MaxUint64).String())
+}
+
+func TestValueIsFinite(t *testing.T) {
+	require.True(t, Float64Value(1).IsFinite())
+	require.True(t, Uint64Value(1).IsFinite())
+	require.False(t, Float64Value(math.NaN()).IsFinite())
+	require.False(t, Float64Value(math.Inf(-1)).IsFinite())
+
+	_, err := newMetrics(Status{Name: "test", MetricType: gaugeType, Value: Float64Value(math.Inf(1))})
+	require.Error(t, err)
+}
@@ -0,0 +1,147 @@
+// Package auth have API tokens with permissions.
+// Tokens are stored in config file only as SHA-256 hashes:
+//
+//	tokens:
+//	  - name: agent-prod
+//	    hash: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	    scopes: [write:metrics]
+//	    prefixes: [App]
+//
+// Hash of token can be calculated with: echo -n "$TOKEN" | sha256sum
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scope is permission of token
+type Scope string
+
+const (
+	// ScopeRead allows reading metrics
+	ScopeRead Scope = "read:metrics"
+	// ScopeWrite allows creating and updating metrics
+	ScopeWrite Scope = "write:metrics"
+	// ScopeAdmin allows everything
+	ScopeAdmin Scope = "admin"
+)
+
+// Token with permissions
+type Token struct {
+	Name   string  `yaml:"name"`
+	Hash   string  `yaml:"hash"`
+	Scopes []Scope `yaml:"scopes"`
+
+	// Prefixes of metric names which token has access to.
+	// If Prefixes is empty token has access to all metrics, else it has no access
+	// to endpoints without metric name, e.g. list of all metrics
+	Prefixes []string `yaml:"prefixes"`
+}
+
+// Store with tokens indexed by hash
+type Store struct {
+	tokens map[string]*Token
+}
+
+// NewStore constructor for Store. Returns error if token is invalid
+func NewStore(tokens []Token) (*Store, error) {
+	s := &Store{
+		tokens: make(map[string]*Token, len(tokens)),
+	}
+
+	for i := range tokens {
+		t := tokens[i]
+		t.Hash = strings.ToLower(t.Hash)
+
+		if b, err := hex.DecodeString(t.Hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("token %q: hash must be hex encoded SHA-256", t.Name)
+		}
+
+		if len(t.Scopes) == 0 {
+			return nil, fmt.Errorf("token %q: scopes are empty", t.Name)
+		}
+
+		for _, scope := range t.Scopes {
+			if scope != ScopeRead && scope != ScopeWrite && scope != ScopeAdmin {
+				return nil, fmt.Errorf("token %q: unknown scope %q", t.Name, scope)
+			}
+		}
+
+		if _, ok := s.tokens[t.Hash]; ok {
+			return nil, fmt.Errorf("token %q: duplicate hash", t.Name)
+		}
+
+		s.tokens[t.Hash] = &t
+	}
+
+	return s, nil
+}
+
+// ReadStore read file with tokens and load it
+func ReadStore(path string) (*Store, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f struct {
+		Tokens []Token `yaml:"tokens"`
+	}
+
+	if err = yaml.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+
+	return NewStore(f.Tokens)
+}
+
+// Lookup returning token by its plain value
+func (s *Store) Lookup(token string) (*Token, bool) {
+	t, ok := s.tokens[HashToken(token)]
+
+	return t, ok
+}
+
+// HashToken returning hex encoded SHA-256 of token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Allows checking that token has scope
+func (t *Token) Allows(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsMetric checking that token has access to metric with name
+func (t *Token) AllowsMetric(name string) bool {
+	if len(t.Prefixes) == 0 {
+		return true
+	}
+
+	for _, p := range t.Prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Restricted reports whether token has access only to part of metrics
+func (t *Token) Restricted() bool {
+	return len(t.Prefixes) > 0
+}
@@ -0,0 +1,15 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStoreValidation(t *testing.T) {
+	_, err := NewStore([]Token{{Name: "bad hash", Hash: "123", Scopes: []Scope{ScopeRead}}})
+	require.Error(t, err)
+
+	_, err = NewStore([]Token{{Name: "bad scope", Hash: HashToken("x"), Scopes: []Scope{"root"}}})
+	require.Error(t, err)
+}
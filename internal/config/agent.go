@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -9,12 +10,120 @@ import (
 
 // AgentConfig configuration for agent
 type AgentConfig struct {
+	Host                 string                   `yaml:"host"`
+	Timeout              time.Duration            `yaml:"timeout"`
+	MaxIdleConns         int                      `yaml:"maxIdleConns"`
+	MaxRequestsPerMoment int                      `yaml:"maxRequestsPerMoment"`
+	QueueSize            int                      `yaml:"queueSize"`
+	RequestsPerSecond    float64                  `yaml:"requestsPerSecond"`
+	Burst                int                      `yaml:"burst"`
+	ReportInterval       time.Duration            `yaml:"reportInterval"`
+	PollInterval         time.Duration            `yaml:"pollInterval"`
+	DisableCompression   bool                     `yaml:"disableCompression"`
+	Key                  string                   `yaml:"key"`
+	CryptoKey            string                   `yaml:"cryptoKey"`
+	TLSCA                string                   `yaml:"tlsCA"`
+	TLSCert              string                   `yaml:"tlsCert"`
+	TLSKey               string                   `yaml:"tlsKey"`
+	TLSServerName        string                   `yaml:"tlsServerName"`
+	Token                string                   `yaml:"token"`
+	TokenFile            string                   `yaml:"tokenFile"`
+	Retries              int                      `yaml:"retries"`
+	RetryInitialInterval time.Duration            `yaml:"retryInitialInterval"`
+	RetryMaxInterval     time.Duration            `yaml:"retryMaxInterval"`
+	SpoolDir             string                   `yaml:"spoolDir"`
+	SpoolMaxSize         int64                    `yaml:"spoolMaxSize"`
+	ShutdownTimeout      time.Duration            `yaml:"shutdownTimeout"`
+	ResyncInterval       time.Duration            `yaml:"resyncInterval"`
+	AlwaysReport         []string                 `yaml:"alwaysReport"`
+	SpoolMaxAge          time.Duration            `yaml:"spoolMaxAge"`
+	ProcRoot             string                   `yaml:"procRoot"`
+	SysRoot              string                   `yaml:"sysRoot"`
+	HostFilesystems      []string                 `yaml:"hostFilesystems"`
+	Collectors           []string                 `yaml:"collectors"`
+	CollectorIntervals   map[string]time.Duration `yaml:"collectorIntervals"`
+	CollectorTimeout     time.Duration            `yaml:"collectorTimeout"`
+	RuntimeCompat        bool                     `yaml:"runtimeCompat"`
+	ListenAddress        string                   `yaml:"listenAddress"`
+	DisablePush          bool                     `yaml:"disablePush"`
+	Once                 bool                     `yaml:"once"`
+	DryRun               bool                     `yaml:"dryRun"`
+	DryRunFormat         string                   `yaml:"dryRunFormat"`
+	RelabelFile          string                   `yaml:"relabelFile"`
+	MetricPrefix         string                   `yaml:"metricPrefix"`
+	Labels               map[string]string        `yaml:"labels"`
+	AllowMetrics         string                   `yaml:"allowMetrics"`
+	DenyMetrics          string                   `yaml:"denyMetrics"`
+	Destinations         []DestinationConfig      `yaml:"destinations"`
+	DestinationsFile     string                   `yaml:"destinationsFile"`
+	Failover             bool                     `yaml:"failover"`
+	Aggregate            []string                 `yaml:"aggregate"`
+}
+
+// DestinationConfig configuration of one server which receives reports.
+// Empty options are taken from AgentConfig, credentials and TLS options only if InheritCredentials is set
+type DestinationConfig struct {
+	Name                 string        `yaml:"name"`
 	Host                 string        `yaml:"host"`
 	Timeout              time.Duration `yaml:"timeout"`
 	MaxIdleConns         int           `yaml:"maxIdleConns"`
 	MaxRequestsPerMoment int           `yaml:"maxRequestsPerMoment"`
-	ReportInterval       time.Duration `yaml:"reportInterval"`
-	PollInterval         time.Duration `yaml:"pollInterval"`
+	QueueSize            int           `yaml:"queueSize"`
+	RequestsPerSecond    float64       `yaml:"requestsPerSecond"`
+	Burst                int           `yaml:"burst"`
+	DisableCompression   bool          `yaml:"disableCompression"`
+	InheritCredentials   bool          `yaml:"inheritCredentials"`
+	Key                  string        `yaml:"key"`
+	CryptoKey            string        `yaml:"cryptoKey"`
+	TLSCA                string        `yaml:"tlsCA"`
+	TLSCert              string        `yaml:"tlsCert"`
+	TLSKey               string        `yaml:"tlsKey"`
+	TLSServerName        string        `yaml:"tlsServerName"`
+	Token                string        `yaml:"token"`
+	TokenFile            string        `yaml:"tokenFile"`
+	Retries              int           `yaml:"retries"`
+	RetryInitialInterval time.Duration `yaml:"retryInitialInterval"`
+	RetryMaxInterval     time.Duration `yaml:"retryMaxInterval"`
+	SpoolDir             string        `yaml:"spoolDir"`
+	SpoolMaxSize         int64         `yaml:"spoolMaxSize"`
+	SpoolMaxAge          time.Duration `yaml:"spoolMaxAge"`
+}
+
+// ReadDestinations read file with list of destinations
+func ReadDestinations(path string) ([]DestinationConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c struct {
+		Destinations []DestinationConfig `yaml:"destinations"`
+	}
+
+	if err = yaml.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+
+	return c.Destinations, nil
+}
+
+// ParseDestinations parsing list of "host" or "name=host" elements.
+// Destinations from list have no options, so they use credentials of agent
+func ParseDestinations(list []string) []DestinationConfig {
+	result := make([]DestinationConfig, 0, len(list))
+
+	for _, v := range list {
+		d := DestinationConfig{Host: v, InheritCredentials: true}
+
+		if name, host, ok := strings.Cut(v, "="); ok {
+			d.Name = strings.TrimSpace(name)
+			d.Host = strings.TrimSpace(host)
+		}
+
+		result = append(result, d)
+	}
+
+	return result
 }
 
 // ReadAgentConfig read file with configuration and load it
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CtlConfig configuration for mtrctl
+type CtlConfig struct {
+	Address string `yaml:"address"`
+
+	// Key for HMAC-SHA256 signing. If Key is empty signing is disabled
+	Key string `yaml:"key"`
+
+	// Path to PEM file with public key of server for encryption of
+	// request bodies. If CryptoKey is empty encryption is disabled
+	CryptoKey string `yaml:"cryptoKey"`
+
+	// Bearer token for server API. If TokenFile is set token is read from file
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"tokenFile"`
+
+	// TLS options. If any of them is set or address has scheme https, TLS is used
+	TLSCA         string `yaml:"tlsCA"`
+	TLSCert       string `yaml:"tlsCert"`
+	TLSKey        string `yaml:"tlsKey"`
+	TLSServerName string `yaml:"tlsServerName"`
+}
+
+// ReadCtlConfig read file with configuration and load it
+func ReadCtlConfig(path string) (*CtlConfig, error) {
+	c := &CtlConfig{}
+
+	b, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(b, c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StringFromEnv overrides value with environment variable if it is set
+func StringFromEnv(dst *string, name string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dst = v
+	}
+}
+
+// StringsFromEnv overrides values with comma separated list from environment variable if it is set
+func StringsFromEnv(dst *[]string, name string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dst = SplitList(v)
+	}
+}
+
+// IntFromEnv overrides value with integer from environment variable if it is set
+func IntFromEnv(dst *int, name string) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid value of %s: %w", name, err)
+	}
+
+	*dst = i
+
+	return nil
+}
+
+// FloatFromEnv overrides value with float from environment variable if it is set
+func FloatFromEnv(dst *float64, name string) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("invalid value of %s: %w", name, err)
+	}
+
+	*dst = f
+
+	return nil
+}
+
+// SplitList splitting comma separated list and skipping empty elements
+func SplitList(s string) []string {
+	result := make([]string, 0)
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// ParseDurations parsing list of "name=duration" elements
+func ParseDurations(list []string) (map[string]time.Duration, error) {
+	result := make(map[string]time.Duration, len(list))
+
+	for _, v := range list {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected name=duration, got %q", v)
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration for %s: %w", name, err)
+		}
+
+		result[strings.TrimSpace(name)] = d
+	}
+
+	return result, nil
+}
+
+// ParseLabels parsing list of "name=value" elements
+func ParseLabels(list []string) (map[string]string, error) {
+	result := make(map[string]string, len(list))
+
+	for _, v := range list {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected name=value, got %q", v)
+		}
+
+		result[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return result, nil
+}
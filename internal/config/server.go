@@ -1 +1,54 @@
 package config
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ServerConfig configuration for server
+type ServerConfig struct {
+	Address string `yaml:"address"`
+	Key     string `yaml:"key"`
+
+	// Paths to PEM files with private keys. Several keys are used for key rotation
+	CryptoKeys []string `yaml:"cryptoKeys"`
+
+	// TLS is enabled if TLSCert and TLSKey are set.
+	// Mutual TLS is enabled if TLSClientCA is set
+	TLSCert         string   `yaml:"tlsCert"`
+	TLSKey          string   `yaml:"tlsKey"`
+	TLSMinVersion   string   `yaml:"tlsMinVersion"`
+	TLSCipherSuites []string `yaml:"tlsCipherSuites"`
+	TLSClientCA     string   `yaml:"tlsClientCA"`
+
+	// Subnets in CIDR notation allowed to write metrics. If empty writing is allowed from any address
+	TrustedSubnet []string `yaml:"trustedSubnet"`
+	// Subnets in CIDR notation allowed to read metrics. If empty reading is allowed from any address
+	TrustedSubnetRead []string `yaml:"trustedSubnetRead"`
+	// Addresses of reverse proxies allowed to set headers "X-Forwarded-For" and "X-Real-IP".
+	// Headers from other peers are ignored
+	TrustedProxies []string `yaml:"trustedProxies"`
+
+	// Path to YAML file with API tokens. If empty authentication is disabled
+	AuthTokens string `yaml:"authTokens"`
+}
+
+// ReadServerConfig read file with configuration and load it
+func ReadServerConfig(path string) (*ServerConfig, error) {
+	c := &ServerConfig{}
+
+	b, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(b, c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
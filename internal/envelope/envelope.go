@@ -0,0 +1,254 @@
+// Package envelope have hybrid encryption of payloads between agent and server.
+// Payload is encrypted with random AES-256-GCM key and this key
+// is wrapped with RSA-OAEP (SHA-256) by public key of server.
+//
+// Encrypted payload layout:
+//
+//	[2 bytes: length of wrapped key][wrapped key][12 bytes: nonce][ciphertext with tag]
+//
+// Public key is identified by key ID which is derived from the key itself,
+// so server can keep several private keys during key rotation.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// Header contains encryption scheme of request body
+	Header = "X-Encryption"
+	// KeyIDHeader contains ID of public key which was used for encryption
+	KeyIDHeader = "X-Key-ID"
+	// Scheme is value of Header for this package
+	Scheme = "rsa-oaep-aes256-gcm"
+
+	aesKeySize = 32
+)
+
+var (
+	// ErrUnknownKey returned when there is no private key with requested ID
+	ErrUnknownKey = errors.New("unknown key id")
+	// ErrMalformed returned when payload has wrong layout
+	ErrMalformed = errors.New("malformed encrypted payload")
+)
+
+// Encrypter encrypts payloads with public key
+type Encrypter struct {
+	pub   *rsa.PublicKey
+	keyID string
+}
+
+// NewEncrypter constructor for Encrypter
+func NewEncrypter(pub *rsa.PublicKey) *Encrypter {
+	return &Encrypter{
+		pub:   pub,
+		keyID: KeyID(pub),
+	}
+}
+
+// KeyID returning ID of public key
+func (e *Encrypter) KeyID() string {
+	return e.keyID
+}
+
+// Encrypt returning encrypted payload
+func (e *Encrypter) Encrypt(plain []byte) ([]byte, error) {
+	key := make([]byte, aesKeySize)
+
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, e.pub, key, []byte(e.keyID))
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 2, 2+len(wrapped)+len(nonce)+len(plain)+gcm.Overhead())
+	binary.BigEndian.PutUint16(out, uint16(len(wrapped)))
+
+	out = append(out, wrapped...)
+	out = append(out, nonce...)
+
+	return gcm.Seal(out, nonce, plain, []byte(e.keyID)), nil
+}
+
+// Decrypter decrypts payloads with one of private keys
+type Decrypter struct {
+	keys map[string]*rsa.PrivateKey
+}
+
+// NewDecrypter constructor for Decrypter. Several keys are used for key rotation
+func NewDecrypter(keys ...*rsa.PrivateKey) *Decrypter {
+	d := &Decrypter{
+		keys: make(map[string]*rsa.PrivateKey, len(keys)),
+	}
+
+	for _, k := range keys {
+		d.keys[KeyID(&k.PublicKey)] = k
+	}
+
+	return d
+}
+
+// Decrypt returning decrypted payload which was encrypted by public key with keyID
+func (d *Decrypter) Decrypt(keyID string, data []byte) ([]byte, error) {
+	priv, ok := d.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	if len(data) < 2 {
+		return nil, ErrMalformed
+	}
+
+	n := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+
+	if len(data) < n {
+		return nil, ErrMalformed
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), nil, priv, data[:n], []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[n:]
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(keyID))
+}
+
+// KeyID returning ID of public key: first 8 bytes of SHA-256 of PKIX form in hex
+func KeyID(pub *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		// RSA public keys are always marshaled
+		der = x509.MarshalPKCS1PublicKey(pub)
+	}
+
+	sum := sha256.Sum256(der)
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// ReadPublicKey reading RSA public key from PEM file.
+// Supported blocks: "PUBLIC KEY", "RSA PUBLIC KEY" and "CERTIFICATE"
+func ReadPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		return rsaPublicKey(key)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		return rsaPublicKey(cert.PublicKey)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, path)
+	}
+}
+
+// ReadPrivateKey reading RSA private key from PEM file.
+// Supported blocks: "RSA PRIVATE KEY" and "PRIVATE KEY"
+func ReadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("key in %s is not RSA key", path)
+		}
+
+		return priv, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, path)
+	}
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+
+	return block, nil
+}
+
+func rsaPublicKey(key interface{}) (*rsa.PublicKey, error) {
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not RSA key")
+	}
+
+	return pub, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
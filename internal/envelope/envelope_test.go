@@ -0,0 +1,54 @@
+package envelope
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	plain := []byte(`{"id":"Alloc","type":"gauge","value":1}`)
+
+	// Server accepts both keys during rotation
+	d := NewDecrypter(oldKey, newKey)
+
+	for _, k := range []*rsa.PrivateKey{oldKey, newKey} {
+		e := NewEncrypter(&k.PublicKey)
+
+		data, err := e.Encrypt(plain)
+		require.NoError(t, err)
+
+		got, err := d.Decrypt(e.KeyID(), data)
+		require.NoError(t, err)
+		require.Equal(t, plain, got)
+
+		// Tampered payload must be rejected
+		data[len(data)-1] ^= 0xff
+
+		_, err = d.Decrypt(e.KeyID(), data)
+		require.Error(t, err)
+	}
+
+	e := NewEncrypter(&otherKey.PublicKey)
+
+	data, err := e.Encrypt(plain)
+	require.NoError(t, err)
+
+	_, err = d.Decrypt(e.KeyID(), data)
+	require.True(t, errors.Is(err, ErrUnknownKey))
+
+	_, err = d.Decrypt(KeyID(&oldKey.PublicKey), []byte{0})
+	require.True(t, errors.Is(err, ErrMalformed))
+}
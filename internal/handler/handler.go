@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
 
@@ -15,14 +16,22 @@ import (
 const (
 	metricTypeGauge   = "gauge"
 	metricTypeCounter = "counter"
+
+	contentTypeHeader = "Content-Type"
+	contentTypeJSON   = "application/json"
+	contentTypeHTML   = "text/html; charset=utf-8"
 )
 
 type metricService interface {
 	GetGauge(ctx context.Context, name string) (model.GetGaugeDTO, error)
 	GetCounter(ctx context.Context, name string) (model.GetCounterDTO, error)
 	GetAll(ctx context.Context) ([]model.GetAllDTO, error)
+	GetGauges(ctx context.Context) ([]model.GetGaugeDTO, error)
+	GetCounters(ctx context.Context) ([]model.GetCounterDTO, error)
 	PutGauge(ctx context.Context, dto model.PutGaugeDTO) error
 	PutCounter(ctx context.Context, dto model.PutCounterDTO) error
+	DeleteGauge(ctx context.Context, name string) error
+	DeleteCounter(ctx context.Context, name string) error
 }
 
 // Handler implementing all handlers for server
@@ -34,6 +43,13 @@ type Handler struct {
 type Config struct {
 	Router *mux.Router
 	MetSrv metricService
+
+	// Middlewares only for endpoints which read metrics
+	ReadMiddlewares []mux.MiddlewareFunc
+	// Middlewares only for endpoints which create or update metrics
+	WriteMiddlewares []mux.MiddlewareFunc
+	// Middlewares only for endpoints which delete metrics
+	AdminMiddlewares []mux.MiddlewareFunc
 }
 
 // New is constructor for Handler
@@ -42,9 +58,28 @@ func New(c *Config) {
 		metSrv: c.MetSrv,
 	}
 
-	c.Router.HandleFunc("/", panicMiddleware(h.GetStaticAllMetrics)).Methods(http.MethodGet)
-	c.Router.HandleFunc("/update/{metric_type}/{metric_name}/{value}", panicMiddleware(h.UpdateMetric)).Methods(http.MethodPost)
-	c.Router.HandleFunc("/value/{metric_type}/{metric_name}", panicMiddleware(h.GetMetric)).Methods(http.MethodGet)
+	read := c.Router.NewRoute().Subrouter()
+	read.Use(c.ReadMiddlewares...)
+
+	write := c.Router.NewRoute().Subrouter()
+	write.Use(c.WriteMiddlewares...)
+
+	admin := c.Router.NewRoute().Subrouter()
+	admin.Use(c.AdminMiddlewares...)
+
+	// Health check is available without authentication
+	c.Router.HandleFunc("/ping", panicMiddleware(h.Ping)).Methods(http.MethodGet)
+
+	read.HandleFunc("/", panicMiddleware(h.GetStaticAllMetrics)).Methods(http.MethodGet)
+	read.HandleFunc("/value/{metric_type}/{metric_name}", panicMiddleware(h.GetMetric)).Methods(http.MethodGet)
+	read.HandleFunc("/value/", panicMiddleware(h.GetMetricJSON)).Methods(http.MethodPost)
+	read.HandleFunc("/values/", panicMiddleware(h.GetAllMetricsJSON)).Methods(http.MethodGet)
+
+	admin.HandleFunc("/value/{metric_type}/{metric_name}", panicMiddleware(h.DeleteMetric)).Methods(http.MethodDelete)
+
+	write.HandleFunc("/update/{metric_type}/{metric_name}/{value}", panicMiddleware(h.UpdateMetric)).Methods(http.MethodPost)
+	write.HandleFunc("/update/", panicMiddleware(h.UpdateMetricJSON)).Methods(http.MethodPost)
+	write.HandleFunc("/updates/", panicMiddleware(h.UpdatesMetricJSON)).Methods(http.MethodPost)
 }
 
 // For recover in request process with panic
@@ -104,8 +139,9 @@ func (h *Handler) UpdateMetric(w http.ResponseWriter, r *http.Request) {
 	case metricTypeGauge:
 		valueFloat64, err := strconv.ParseFloat(value, 64)
 
-		if err != nil {
-			msg := fmt.Sprintf("unable to parse value. Expected: float. Actual: %s", value)
+		// ParseFloat accepts "NaN" and "Inf", but they could not be stored and returned in JSON
+		if err != nil || !isFinite(valueFloat64) {
+			msg := fmt.Sprintf("unable to parse value. Expected: finite float. Actual: %s", value)
 			log.Println(msg)
 			http.Error(w, msg, http.StatusBadRequest)
 
@@ -239,6 +275,56 @@ func (h *Handler) GetMetric(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// DeleteMetric removing metric by type and name
+func (h *Handler) DeleteMetric(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	metricType := vars["metric_type"]
+	metricName := vars["metric_name"]
+
+	if len(metricName) == 0 {
+		http.Error(w, "unable parse path parameter 'metric_name'. Expected: string with length > 0", http.StatusBadRequest)
+
+		return
+	}
+
+	var err error
+
+	switch metricType {
+	case metricTypeGauge:
+		err = h.metSrv.DeleteGauge(ctx, metricName)
+	case metricTypeCounter:
+		err = h.metSrv.DeleteCounter(ctx, metricName)
+	default:
+		msg := fmt.Sprintf("unknown metric type. Expected %s or %s. Actual: %s", metricTypeGauge, metricTypeCounter, metricType)
+		log.Println(msg)
+		http.Error(w, msg, http.StatusNotImplemented)
+
+		return
+	}
+
+	if err != nil {
+		// Repository returns error only if metric not exists
+		msg := fmt.Sprintf("unable to delete %s metric with name=%s", metricType, metricName)
+		log.Println(msg)
+		http.Error(w, msg, http.StatusNotFound)
+
+		return
+	}
+
+	if _, err = w.Write([]byte("OK")); err != nil {
+		log.Printf("unable to write body. Error: %s\n", err)
+	}
+}
+
+// Ping return OK if server is ready to accept requests
+func (h *Handler) Ping(w http.ResponseWriter, _ *http.Request) {
+	if _, err := w.Write([]byte("OK")); err != nil {
+		log.Printf("unable to write body. Error: %s\n", err)
+	}
+}
+
 // GetStaticAllMetrics return HTML with information about all metrics which exist
 func (h *Handler) GetStaticAllMetrics(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -266,6 +352,8 @@ func (h *Handler) GetStaticAllMetrics(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 	}
 
+	w.Header().Set(contentTypeHeader, contentTypeHTML)
+
 	err = tmpl.Execute(w, data)
 
 	if err != nil {
@@ -273,3 +361,8 @@ func (h *Handler) GetStaticAllMetrics(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 	}
 }
+
+// isFinite reports whether value is not NaN or infinity
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
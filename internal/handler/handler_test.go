@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mtrrun/internal/repository"
+	"github.com/mtrrun/internal/service"
+)
+
+func newTestRouter() *mux.Router {
+	r := mux.NewRouter()
+
+	New(&Config{
+		Router: r,
+		MetSrv: service.NewMetricService(&service.MetricServiceConfig{
+			MetRepo: repository.NewMetricMemCache(),
+		}),
+	})
+
+	return r
+}
+
+func do(r http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+
+	return w
+}
+
+func TestUpdateMetricRejectsNotFinite(t *testing.T) {
+	r := newTestRouter()
+
+	for _, v := range []string{"NaN", "Inf", "-Inf", "+Inf", "nan"} {
+		w := do(r, http.MethodPost, "/update/gauge/test/"+v)
+		require.Equal(t, http.StatusBadRequest, w.Code, v)
+	}
+}
+
+// Gauge which is sent with the shortest representation is returned without changes
+func TestGaugeRoundTrip(t *testing.T) {
+	r := newTestRouter()
+
+	f := func(v float64) bool {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return true
+		}
+
+		w := do(r, http.MethodPost, "/update/gauge/test/"+strconv.FormatFloat(v, 'g', -1, 64))
+		if w.Code != http.StatusOK {
+			return false
+		}
+
+		w = do(r, http.MethodGet, "/value/gauge/test")
+		got, err := strconv.ParseFloat(w.Body.String(), 64)
+
+		return err == nil && got == v
+	}
+
+	require.NoError(t, quick.Check(f, nil))
+	require.True(t, f(0.0003))
+	require.True(t, f(math.MaxFloat64))
+	require.True(t, f(math.SmallestNonzeroFloat64))
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/mtrrun/internal/model"
+)
+
+// UpdateMetricJSON accepts request with metric in JSON body for create or update metrics
+func (h *Handler) UpdateMetricJSON(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var m model.Metrics
+
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		msg := fmt.Sprintf("unable to decode body. Error: %s", err)
+		log.Println(msg)
+		http.Error(w, msg, http.StatusBadRequest)
+
+		return
+	}
+
+	if status, msg := validateMetric(m); status != http.StatusOK {
+		log.Println(msg)
+		http.Error(w, msg, status)
+
+		return
+	}
+
+	if err := h.putMetric(ctx, m); err != nil {
+		msg := fmt.Sprintf("unable to update/create %s metric with name=%s", m.MType, m.ID)
+		log.Println(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+
+		return
+	}
+
+	h.writeMetricJSON(w, r, m.ID, m.MType)
+}
+
+// UpdatesMetricJSON accepts request with list of metrics in JSON body for create or update metrics.
+// List is rejected if any metric is invalid
+func (h *Handler) UpdatesMetricJSON(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var list []model.Metrics
+
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		msg := fmt.Sprintf("unable to decode body. Error: %s", err)
+		log.Println(msg)
+		http.Error(w, msg, http.StatusBadRequest)
+
+		return
+	}
+
+	for _, m := range list {
+		if status, msg := validateMetric(m); status != http.StatusOK {
+			log.Println(msg)
+			http.Error(w, msg, status)
+
+			return
+		}
+	}
+
+	for _, m := range list {
+		if err := h.putMetric(ctx, m); err != nil {
+			msg := fmt.Sprintf("unable to update/create %s metric with name=%s", m.MType, m.ID)
+			log.Println(msg)
+			http.Error(w, msg, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+
+	if _, err := w.Write([]byte("{}")); err != nil {
+		log.Printf("unable to write body. Error: %s\n", err)
+	}
+}
+
+// GetMetricJSON return metric in JSON body by id and type from request body
+func (h *Handler) GetMetricJSON(w http.ResponseWriter, r *http.Request) {
+	var m model.Metrics
+
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		msg := fmt.Sprintf("unable to decode body. Error: %s", err)
+		log.Println(msg)
+		http.Error(w, msg, http.StatusBadRequest)
+
+		return
+	}
+
+	if len(m.ID) == 0 {
+		http.Error(w, "unable to parse id. Expected: string with length > 0", http.StatusBadRequest)
+
+		return
+	}
+
+	switch m.MType {
+	case metricTypeGauge, metricTypeCounter:
+		h.writeMetricJSON(w, r, m.ID, m.MType)
+	default:
+		msg := fmt.Sprintf("unknown metric type. Expected %s or %s. Actual: %s", metricTypeGauge, metricTypeCounter, m.MType)
+		log.Println(msg)
+		http.Error(w, msg, http.StatusNotImplemented)
+	}
+}
+
+// GetAllMetricsJSON return all metrics in JSON body sorted by type and id
+func (h *Handler) GetAllMetricsJSON(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	gauges, err := h.metSrv.GetGauges(ctx)
+	if err != nil {
+		log.Printf("unable to get all gauge metrics. Error: %s\n", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+
+		return
+	}
+
+	counters, err := h.metSrv.GetCounters(ctx)
+	if err != nil {
+		log.Printf("unable to get all counter metrics. Error: %s\n", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+
+		return
+	}
+
+	result := make([]model.Metrics, 0, len(counters)+len(gauges))
+
+	for i := range counters {
+		result = append(result, model.Metrics{
+			ID:    counters[i].Name,
+			MType: metricTypeCounter,
+			Delta: &counters[i].Value,
+		})
+	}
+
+	for i := range gauges {
+		result = append(result, model.Metrics{
+			ID:    gauges[i].Name,
+			MType: metricTypeGauge,
+			Value: &gauges[i].Value,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].MType != result[j].MType {
+			return result[i].MType < result[j].MType
+		}
+
+		return result[i].ID < result[j].ID
+	})
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+
+	if err = json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("unable to write body. Error: %s\n", err)
+	}
+}
+
+// validateMetric checking metric from request.
+// Returns http.StatusOK if metric is valid, else status and message for response
+func validateMetric(m model.Metrics) (int, string) {
+	if len(m.ID) == 0 {
+		return http.StatusBadRequest, "unable to parse id. Expected: string with length > 0"
+	}
+
+	switch m.MType {
+	case metricTypeGauge:
+		if m.Value == nil || !isFinite(*m.Value) {
+			return http.StatusBadRequest, fmt.Sprintf("unable to parse value for gauge metric with id=%s. Expected: finite float", m.ID)
+		}
+	case metricTypeCounter:
+		if m.Delta == nil {
+			return http.StatusBadRequest, fmt.Sprintf("unable to parse delta for counter metric with id=%s. Expected: int", m.ID)
+		}
+	default:
+		return http.StatusNotImplemented, fmt.Sprintf("unknown metric type. Expected %s or %s. Actual: %s", metricTypeGauge, metricTypeCounter, m.MType)
+	}
+
+	return http.StatusOK, ""
+}
+
+// putMetric creating or updating valid metric
+func (h *Handler) putMetric(ctx context.Context, m model.Metrics) error {
+	if m.MType == metricTypeGauge {
+		return h.metSrv.PutGauge(ctx, model.PutGaugeDTO{
+			Name:  m.ID,
+			Value: *m.Value,
+		})
+	}
+
+	return h.metSrv.PutCounter(ctx, model.PutCounterDTO{
+		Name:  m.ID,
+		Value: *m.Delta,
+	})
+}
+
+// writeMetricJSON selecting actual metric state and writing it as JSON
+func (h *Handler) writeMetricJSON(w http.ResponseWriter, r *http.Request, id, mType string) {
+	ctx := r.Context()
+
+	result := model.Metrics{
+		ID:    id,
+		MType: mType,
+	}
+
+	if mType == metricTypeGauge {
+		metric, err := h.metSrv.GetGauge(ctx, id)
+
+		if err != nil {
+			msg := fmt.Sprintf("unable to select gauge metric with name=%s", id)
+			log.Println(msg)
+			http.Error(w, msg, http.StatusNotFound)
+
+			return
+		}
+
+		result.Value = &metric.Value
+	} else {
+		metric, err := h.metSrv.GetCounter(ctx, id)
+
+		if err != nil {
+			msg := fmt.Sprintf("unable to select counter metric with name=%s", id)
+			log.Println(msg)
+			http.Error(w, msg, http.StatusNotFound)
+
+			return
+		}
+
+		result.Delta = &metric.Value
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("unable to write body. Error: %s\n", err)
+	}
+}
@@ -0,0 +1,405 @@
+// Package host reads metrics of Linux host from /proc and /sys
+// and usage of file systems with statfs
+package host
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProcRoot = "/proc"
+	defaultSysRoot  = "/sys"
+
+	// Size of sector in /proc/diskstats, it doesn't depend on device
+	sectorSize = 512
+)
+
+// Config configuration list for Reader
+type Config struct {
+	// Root of proc file system. If ProcRoot is empty that will be use default value - "/proc".
+	ProcRoot string
+
+	// Root of sys file system. If SysRoot is empty that will be use default value - "/sys".
+	SysRoot string
+
+	// Mount points for usage of file systems. Mount points which could not be read are skipped.
+	// If Filesystems is empty usage is not read
+	Filesystems []string
+}
+
+// Stats of host
+type Stats struct {
+	// Memory in bytes
+	TotalMemory     float64
+	FreeMemory      float64
+	AvailableMemory float64
+
+	// Load average for 1, 5 and 15 minutes
+	Load1  float64
+	Load5  float64
+	Load15 float64
+
+	// Utilization of every core in percents since previous read.
+	// First read returns utilization since boot
+	CPUUtilization []float64
+
+	// Disk IO rates of whole disks in bytes per second since previous read.
+	// First read returns zero rates
+	DiskReadBytesPerSec  float64
+	DiskWriteBytesPerSec float64
+
+	// Cumulative network traffic of all interfaces except loopback
+	NetReceivedBytes    uint64
+	NetTransmittedBytes uint64
+
+	Filesystems []FilesystemStats
+}
+
+// FilesystemStats usage of file system in bytes
+type FilesystemStats struct {
+	Path  string
+	Total float64
+	Free  float64
+	Used  float64
+}
+
+type cpuTimes struct {
+	idle  uint64
+	total uint64
+}
+
+type diskCounters struct {
+	readBytes  uint64
+	writeBytes uint64
+}
+
+// Reader reads host metrics. It keeps previous values for calculating rates
+type Reader struct {
+	mu sync.Mutex
+
+	c Config
+
+	prevCPU  []cpuTimes
+	prevDisk *diskCounters
+	prevTime time.Time
+}
+
+// New constructor for Reader
+func New(c *Config) *Reader {
+	if c.ProcRoot == "" {
+		c.ProcRoot = defaultProcRoot
+	}
+
+	if c.SysRoot == "" {
+		c.SysRoot = defaultSysRoot
+	}
+
+	return &Reader{
+		c: *c,
+	}
+}
+
+// Read returning actual host metrics
+func (r *Reader) Read() (*Stats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &Stats{}
+	now := time.Now()
+
+	if err := r.readMemory(s); err != nil {
+		return nil, err
+	}
+
+	if err := r.readLoad(s); err != nil {
+		return nil, err
+	}
+
+	if err := r.readCPU(s); err != nil {
+		return nil, err
+	}
+
+	if err := r.readDisks(s, now); err != nil {
+		return nil, err
+	}
+
+	if err := r.readNetwork(s); err != nil {
+		return nil, err
+	}
+
+	for _, path := range r.c.Filesystems {
+		// File system could be unmounted, other metrics are still read
+		fs, err := statfs(path)
+		if err != nil {
+			log.Printf("unable to read usage of %s: %s\n", path, err)
+
+			continue
+		}
+
+		s.Filesystems = append(s.Filesystems, fs)
+	}
+
+	r.prevTime = now
+
+	return s, nil
+}
+
+// readMemory parsing /proc/meminfo
+func (r *Reader) readMemory(s *Stats) error {
+	values, err := r.readKeyValues("meminfo")
+	if err != nil {
+		return err
+	}
+
+	// Values in /proc/meminfo are in kibibytes
+	s.TotalMemory = float64(values["MemTotal"] * 1024)
+	s.FreeMemory = float64(values["MemFree"] * 1024)
+	s.AvailableMemory = float64(values["MemAvailable"] * 1024)
+
+	return nil
+}
+
+// readLoad parsing /proc/loadavg
+func (r *Reader) readLoad(s *Stats) error {
+	b, err := os.ReadFile(filepath.Join(r.c.ProcRoot, "loadavg"))
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(string(b))
+	if len(fields) < 3 {
+		return fmt.Errorf("unexpected format of loadavg: %q", string(b))
+	}
+
+	loads := make([]float64, 3)
+
+	for i := range loads {
+		if loads[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return fmt.Errorf("unexpected format of loadavg: %w", err)
+		}
+	}
+
+	s.Load1, s.Load5, s.Load15 = loads[0], loads[1], loads[2]
+
+	return nil
+}
+
+// readCPU parsing lines "cpuN" from /proc/stat
+func (r *Reader) readCPU(s *Stats) error {
+	lines, err := r.readLines("stat")
+	if err != nil {
+		return err
+	}
+
+	cur := make([]cpuTimes, 0)
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+
+		// Line "cpu" without number is total for all cores
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+			continue
+		}
+
+		var t cpuTimes
+
+		// user nice system idle iowait irq softirq steal. Guest time is already in user time
+		for i := 1; i < len(fields) && i <= 8; i++ {
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return fmt.Errorf("unexpected format of stat: %w", err)
+			}
+
+			t.total += v
+
+			if i == 4 || i == 5 {
+				t.idle += v
+			}
+		}
+
+		cur = append(cur, t)
+	}
+
+	s.CPUUtilization = make([]float64, len(cur))
+
+	for i, t := range cur {
+		idle, busy := t.idle, t.total-t.idle
+
+		// Number of cores could change with hotplug
+		if len(r.prevCPU) == len(cur) && t.total >= r.prevCPU[i].total {
+			prev := r.prevCPU[i]
+			idle = delta(t.idle, prev.idle)
+			busy = delta(t.total-t.idle, prev.total-prev.idle)
+		}
+
+		if total := idle + busy; total > 0 {
+			s.CPUUtilization[i] = 100 * float64(busy) / float64(total)
+		}
+	}
+
+	r.prevCPU = cur
+
+	return nil
+}
+
+// delta returning difference of cumulative times. Times could decrease, e.g. iowait,
+// such difference is zero
+func delta(cur, prev uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+
+	return cur - prev
+}
+
+// readDisks parsing /proc/diskstats for whole disks
+func (r *Reader) readDisks(s *Stats, now time.Time) error {
+	lines, err := r.readLines("diskstats")
+	if err != nil {
+		return err
+	}
+
+	var cur diskCounters
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || !r.isDisk(fields[2]) {
+			continue
+		}
+
+		read, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			return fmt.Errorf("unexpected format of diskstats: %w", err)
+		}
+
+		written, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return fmt.Errorf("unexpected format of diskstats: %w", err)
+		}
+
+		cur.readBytes += read * sectorSize
+		cur.writeBytes += written * sectorSize
+	}
+
+	if r.prevDisk != nil && cur.readBytes >= r.prevDisk.readBytes && cur.writeBytes >= r.prevDisk.writeBytes {
+		if elapsed := now.Sub(r.prevTime).Seconds(); elapsed > 0 {
+			s.DiskReadBytesPerSec = float64(cur.readBytes-r.prevDisk.readBytes) / elapsed
+			s.DiskWriteBytesPerSec = float64(cur.writeBytes-r.prevDisk.writeBytes) / elapsed
+		}
+	}
+
+	r.prevDisk = &cur
+
+	return nil
+}
+
+// isDisk checking that device is whole disk, not partition or virtual device.
+// Whole disks are listed in /sys/block. If it is unavailable
+// loop and ram devices are skipped only
+func (r *Reader) isDisk(name string) bool {
+	if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+		return false
+	}
+
+	if _, err := os.Stat(filepath.Join(r.c.SysRoot, "block")); err != nil {
+		return true
+	}
+
+	_, err := os.Stat(filepath.Join(r.c.SysRoot, "block", name))
+
+	return err == nil
+}
+
+// readNetwork parsing /proc/net/dev
+func (r *Reader) readNetwork(s *Stats) error {
+	lines, err := r.readLines(filepath.Join("net", "dev"))
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		name, data, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+
+		fields := strings.Fields(data)
+		if len(fields) < 9 {
+			continue
+		}
+
+		received, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("unexpected format of net/dev: %w", err)
+		}
+
+		transmitted, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return fmt.Errorf("unexpected format of net/dev: %w", err)
+		}
+
+		s.NetReceivedBytes += received
+		s.NetTransmittedBytes += transmitted
+	}
+
+	return nil
+}
+
+// readKeyValues parsing file with lines "Key: value unit"
+func (r *Reader) readKeyValues(name string) (map[string]uint64, error) {
+	lines, err := r.readLines(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]uint64, len(lines))
+
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		result[key] = v
+	}
+
+	return result, nil
+}
+
+func (r *Reader) readLines(name string) ([]string, error) {
+	f, err := os.Open(filepath.Join(r.c.ProcRoot, name))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
@@ -0,0 +1,102 @@
+package host
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mtrrun/internal/host/hosttest"
+)
+
+func TestReaderRead(t *testing.T) {
+	root := hosttest.Fixtures(t)
+	proc := filepath.Join(root, "proc")
+
+	r := New(&Config{
+		ProcRoot: proc,
+		SysRoot:  filepath.Join(root, "sys"),
+	})
+
+	s, err := r.Read()
+	require.NoError(t, err)
+
+	require.Equal(t, float64(16303436*1024), s.TotalMemory)
+	require.Equal(t, float64(8151718*1024), s.FreeMemory)
+	require.Equal(t, float64(12227577*1024), s.AvailableMemory)
+
+	require.Equal(t, 0.52, s.Load1)
+	require.Equal(t, 0.58, s.Load5)
+	require.Equal(t, 0.59, s.Load15)
+
+	// Utilization since boot
+	require.Len(t, s.CPUUtilization, 2)
+	require.InDelta(t, 20, s.CPUUtilization[0], 1e-9)
+	require.InDelta(t, 20, s.CPUUtilization[1], 1e-9)
+
+	// Loopback is skipped
+	require.Equal(t, uint64(1000500), s.NetReceivedBytes)
+	require.Equal(t, uint64(200300), s.NetTransmittedBytes)
+
+	require.Zero(t, s.DiskReadBytesPerSec)
+	require.Zero(t, s.DiskWriteBytesPerSec)
+
+	// Core 0 is busy half of time, core 1 is idle
+	stat := "cpu  0 0 0 0 0 0 0 0 0 0\n" +
+		"cpu0 2000 0 500 8000 500 0 0 0 0 0\n" +
+		"cpu1 1500 0 500 7500 1500 0 0 0 0 0\n"
+	require.NoError(t, os.WriteFile(filepath.Join(proc, "stat"), []byte(stat), 0o644))
+
+	// Only sda is whole disk in sys/block, partition and loop device are skipped
+	disks := "   7       0 loop0 200 0 4000 10 0 0 0 0 0 10 10 0 0 0 0\n" +
+		"   8       0 sda 1000 0 5096 100 500 0 4048 50 0 150 150 0 0 0 0\n" +
+		"   8       1 sda1 900 0 5000 90 400 0 4000 40 0 130 130 0 0 0 0\n"
+	require.NoError(t, os.WriteFile(filepath.Join(proc, "diskstats"), []byte(disks), 0o644))
+
+	r.prevTime = r.prevTime.Add(-2 * time.Second)
+
+	s, err = r.Read()
+	require.NoError(t, err)
+
+	require.InDelta(t, 50, s.CPUUtilization[0], 1e-9)
+	require.InDelta(t, 0, s.CPUUtilization[1], 1e-9)
+
+	require.InDelta(t, 1000*sectorSize/2, s.DiskReadBytesPerSec, 1000)
+	require.InDelta(t, 2000*sectorSize/2, s.DiskWriteBytesPerSec, 2000)
+
+	// Idle time with iowait of core 0 decreased, its difference is zero instead of overflow
+	stat = "cpu  0 0 0 0 0 0 0 0 0 0\n" +
+		"cpu0 2100 0 500 8050 400 0 0 0 0 0\n" +
+		"cpu1 1500 0 500 7600 1500 0 0 0 0 0\n"
+	require.NoError(t, os.WriteFile(filepath.Join(proc, "stat"), []byte(stat), 0o644))
+
+	s, err = r.Read()
+	require.NoError(t, err)
+
+	require.InDelta(t, 100, s.CPUUtilization[0], 1e-9)
+	require.InDelta(t, 0, s.CPUUtilization[1], 1e-9)
+}
+
+func TestReaderSkipsUnavailableFilesystem(t *testing.T) {
+	root := hosttest.Fixtures(t)
+
+	r := New(&Config{
+		ProcRoot:    filepath.Join(root, "proc"),
+		SysRoot:     filepath.Join(root, "sys"),
+		Filesystems: []string{filepath.Join(root, "missing")},
+	})
+
+	s, err := r.Read()
+	require.NoError(t, err)
+	require.Empty(t, s.Filesystems)
+	require.Equal(t, float64(16303436*1024), s.TotalMemory)
+}
+
+func TestReaderMissingProc(t *testing.T) {
+	r := New(&Config{ProcRoot: filepath.Join(t.TempDir(), "proc")})
+
+	_, err := r.Read()
+	require.Error(t, err)
+}
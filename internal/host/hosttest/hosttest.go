@@ -0,0 +1,50 @@
+// Package hosttest provides fixtures of proc and sys file systems for tests of host metrics
+package hosttest
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Fixtures copying testdata of host package to temporary directory, so files could be changed in test.
+// Returns root of copy with directories "proc" and "sys"
+func Fixtures(t *testing.T) string {
+	t.Helper()
+
+	_, file, _, ok := runtime.Caller(0)
+	require.True(t, ok, "unable to find path of fixtures")
+
+	src := filepath.Join(filepath.Dir(file), "..", "testdata")
+	dst := t.TempDir()
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0o700)
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(target, b, 0o600)
+	})
+	require.NoError(t, err)
+
+	return dst
+}
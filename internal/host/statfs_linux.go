@@ -0,0 +1,24 @@
+//go:build linux
+
+package host
+
+import "syscall"
+
+// statfs returning usage of file system mounted at path
+func statfs(path string) (FilesystemStats, error) {
+	var st syscall.Statfs_t
+
+	if err := syscall.Statfs(path, &st); err != nil {
+		return FilesystemStats{}, err
+	}
+
+	total := float64(st.Blocks) * float64(st.Bsize)
+	free := float64(st.Bavail) * float64(st.Bsize)
+
+	return FilesystemStats{
+		Path:  path,
+		Total: total,
+		Free:  free,
+		Used:  total - float64(st.Bfree)*float64(st.Bsize),
+	}, nil
+}
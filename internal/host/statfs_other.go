@@ -0,0 +1,10 @@
+//go:build !linux
+
+package host
+
+import "errors"
+
+// statfs is supported only on Linux
+func statfs(path string) (FilesystemStats, error) {
+	return FilesystemStats{}, errors.New("usage of file systems is supported only on linux")
+}
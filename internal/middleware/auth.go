@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mtrrun/internal/auth"
+)
+
+const (
+	authorizationHeader   = "Authorization"
+	wwwAuthenticateHeader = "WWW-Authenticate"
+	bearerPrefix          = "Bearer "
+)
+
+type tokenKey struct{}
+
+// AuthConfig configuration for Auth middleware
+type AuthConfig struct {
+	// Store with tokens. If Store is nil middleware does nothing
+	Store *auth.Store
+
+	// Scope required for endpoints
+	Scope auth.Scope
+}
+
+// Auth checks bearer token from header "Authorization".
+// Requests without valid token are rejected with 401,
+// requests with token without required scope or access
+// to requested metrics are rejected with 403. Token with prefixes
+// has no access to endpoints without metric name.
+func Auth(c *AuthConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if c.Store == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get(authorizationHeader)
+
+			if !strings.HasPrefix(header, bearerPrefix) {
+				log.Printf("request to %s from %s without token\n", r.URL.Path, caller(r))
+				w.Header().Set(wwwAuthenticateHeader, "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+
+			token, ok := c.Store.Lookup(strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix)))
+			if !ok {
+				log.Printf("request to %s from %s with unknown token\n", r.URL.Path, caller(r))
+				w.Header().Set(wwwAuthenticateHeader, `Bearer error="invalid_token"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+
+			if !token.Allows(c.Scope) {
+				log.Printf("token %q of %s has no scope %s for %s\n", token.Name, caller(r), c.Scope, r.URL.Path)
+				http.Error(w, "forbidden", http.StatusForbidden)
+
+				return
+			}
+
+			if token.Restricted() {
+				names, err := metricNames(r)
+				if err != nil {
+					http.Error(w, "unable to read request body", http.StatusBadRequest)
+
+					return
+				}
+
+				// Endpoints without metric name, e.g. list of all metrics, give access
+				// to metrics which are not allowed, so restricted token is rejected
+				allowed := len(names) > 0
+
+				for _, name := range names {
+					if !token.AllowsMetric(name) {
+						allowed = false
+
+						break
+					}
+				}
+
+				if !allowed {
+					log.Printf("token %q of %s has no access to metrics %v\n", token.Name, caller(r), names)
+					http.Error(w, "forbidden", http.StatusForbidden)
+
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenKey{}, token)))
+		})
+	}
+}
+
+// TokenFromContext returning token which was put by Auth
+func TokenFromContext(ctx context.Context) (*auth.Token, bool) {
+	t, ok := ctx.Value(tokenKey{}).(*auth.Token)
+
+	return t, ok
+}
+
+// metricNames returning names of metrics from path or JSON body of request
+func metricNames(r *http.Request) ([]string, error) {
+	if name, ok := mux.Vars(r)["metric_name"]; ok {
+		return []string{name}, nil
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	type metric struct {
+		ID string `json:"id"`
+	}
+
+	var list []metric
+
+	if err = json.Unmarshal(body, &list); err != nil {
+		var single metric
+
+		if err = json.Unmarshal(body, &single); err != nil {
+			// Handler will report invalid body
+			return nil, nil
+		}
+
+		list = []metric{single}
+	}
+
+	names := make([]string, 0, len(list))
+
+	for _, m := range list {
+		names = append(names, m.ID)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mtrrun/internal/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuth(t *testing.T) {
+	store, err := auth.NewStore([]auth.Token{
+		{Name: "writer", Hash: auth.HashToken("writer"), Scopes: []auth.Scope{auth.ScopeWrite}},
+		{Name: "reader", Hash: auth.HashToken("reader"), Scopes: []auth.Scope{auth.ScopeRead}},
+		{Name: "app", Hash: auth.HashToken("app"), Scopes: []auth.Scope{auth.ScopeWrite}, Prefixes: []string{"App"}},
+		{Name: "admin", Hash: auth.HashToken("admin"), Scopes: []auth.Scope{auth.ScopeAdmin}},
+	})
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	r.Use(Auth(&AuthConfig{Store: store, Scope: auth.ScopeWrite}))
+	r.HandleFunc("/update/{metric_type}/{metric_name}/{value}", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodPost)
+	r.HandleFunc("/update/", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodPost)
+
+	tests := []struct {
+		name   string
+		token  string
+		url    string
+		body   string
+		status int
+	}{
+		{name: "without token", url: "/update/gauge/Alloc/1", status: http.StatusUnauthorized},
+		{name: "unknown token", token: "unknown", url: "/update/gauge/Alloc/1", status: http.StatusUnauthorized},
+		{name: "without scope", token: "reader", url: "/update/gauge/Alloc/1", status: http.StatusForbidden},
+		{name: "with scope", token: "writer", url: "/update/gauge/Alloc/1", status: http.StatusOK},
+		{name: "admin", token: "admin", url: "/update/gauge/Alloc/1", status: http.StatusOK},
+		{name: "allowed prefix", token: "app", url: "/update/gauge/AppQueue/1", status: http.StatusOK},
+		{name: "denied prefix", token: "app", url: "/update/gauge/Alloc/1", status: http.StatusForbidden},
+		{name: "allowed prefix in body", token: "app", url: "/update/", body: `{"id":"AppQueue","type":"gauge","value":1}`, status: http.StatusOK},
+		{name: "denied prefix in body", token: "app", url: "/update/", body: `{"id":"Alloc","type":"gauge","value":1}`, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set(authorizationHeader, bearerPrefix+tt.token)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
+
+func TestAuthRestrictedWithoutMetricName(t *testing.T) {
+	store, err := auth.NewStore([]auth.Token{
+		{Name: "reader", Hash: auth.HashToken("reader"), Scopes: []auth.Scope{auth.ScopeRead}},
+		{Name: "app", Hash: auth.HashToken("app"), Scopes: []auth.Scope{auth.ScopeRead}, Prefixes: []string{"App"}},
+	})
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	r.Use(Auth(&AuthConfig{Store: store, Scope: auth.ScopeRead}))
+	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "unrestricted token", token: "reader", status: http.StatusOK},
+		{name: "restricted token", token: "app", status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(authorizationHeader, bearerPrefix+tt.token)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/mtrrun/internal/envelope"
+)
+
+// DecryptConfig configuration for Decrypt middleware
+type DecryptConfig struct {
+	// Decrypter with private keys of server. If Decrypter is nil middleware does nothing
+	Decrypter *envelope.Decrypter
+}
+
+type decryptedKey struct{}
+
+// Decrypt decrypts request bodies encrypted by public key of server.
+// Plaintext requests pass as is, RequireEncryption rejects them on routes which write metrics
+func Decrypt(c *DecryptConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if c.Decrypter == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(envelope.Header) != envelope.Scheme {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			data, err := io.ReadAll(r.Body)
+			if err != nil {
+				log.Printf("unable to read request body. Error: %s\n", err)
+				http.Error(w, "unable to read request body", http.StatusBadRequest)
+
+				return
+			}
+
+			plain, err := c.Decrypter.Decrypt(r.Header.Get(envelope.KeyIDHeader), data)
+			if err != nil {
+				log.Printf("unable to decrypt request body from %s. Error: %s\n", caller(r), err)
+
+				if errors.Is(err, envelope.ErrUnknownKey) {
+					http.Error(w, "unknown encryption key", http.StatusBadRequest)
+
+					return
+				}
+
+				http.Error(w, "unable to decrypt request body", http.StatusBadRequest)
+
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(plain))
+			r.ContentLength = int64(len(plain))
+			r.Header.Del(envelope.Header)
+			r.Header.Del(envelope.KeyIDHeader)
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), decryptedKey{}, true)))
+		})
+	}
+}
+
+// RequireEncryption rejects requests with status 400 if they were not decrypted by Decrypt,
+// because plaintext metrics are not allowed when server has private keys.
+// It is used for routes which write metrics. Reading routes and admin routes accept
+// plaintext requests, because their requests don't contain metrics
+func RequireEncryption(c *DecryptConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if c.Decrypter == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if decrypted, _ := r.Context().Value(decryptedKey{}).(bool); !decrypted {
+				http.Error(w, "request body must be encrypted", http.StatusBadRequest)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mtrrun/internal/envelope"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecrypt(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	body := []byte(`{"id":"Alloc","type":"gauge","value":1}`)
+
+	e := envelope.NewEncrypter(&key.PublicKey)
+	encrypted, err := e.Encrypt(body)
+	require.NoError(t, err)
+
+	other := envelope.NewEncrypter(&otherKey.PublicKey)
+	encryptedByOther, err := other.Encrypt(body)
+	require.NoError(t, err)
+
+	tampered := append([]byte(nil), encrypted...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	c := &DecryptConfig{Decrypter: envelope.NewDecrypter(key)}
+
+	// Handlers return body which they received
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(b)
+	}
+
+	r := mux.NewRouter()
+	r.Use(Decrypt(c))
+	r.HandleFunc("/value/", echo).Methods(http.MethodGet)
+
+	write := r.PathPrefix("/update/").Subrouter()
+	write.Use(RequireEncryption(c))
+	write.HandleFunc("/", echo).Methods(http.MethodPost)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		keyID  string
+		body   []byte
+		status int
+		want   string
+	}{
+		{name: "encrypted", method: http.MethodPost, url: "/update/", keyID: e.KeyID(), body: encrypted, status: http.StatusOK, want: string(body)},
+		{name: "plaintext", method: http.MethodPost, url: "/update/", body: body, status: http.StatusBadRequest, want: "must be encrypted"},
+		{name: "unknown key", method: http.MethodPost, url: "/update/", keyID: other.KeyID(), body: encryptedByOther, status: http.StatusBadRequest, want: "unknown encryption key"},
+		{name: "not decrypted", method: http.MethodPost, url: "/update/", keyID: e.KeyID(), body: tampered, status: http.StatusBadRequest, want: "unable to decrypt"},
+		{name: "plaintext read", method: http.MethodGet, url: "/value/", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, bytes.NewReader(tt.body))
+			if tt.keyID != "" {
+				req.Header.Set(envelope.Header, envelope.Scheme)
+				req.Header.Set(envelope.KeyIDHeader, tt.keyID)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+			require.Contains(t, rec.Body.String(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package middleware have http middlewares for server
+package middleware
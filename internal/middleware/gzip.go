@@ -0,0 +1,269 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	encodingGzip = "gzip"
+
+	contentEncodingHeader = "Content-Encoding"
+	acceptEncodingHeader  = "Accept-Encoding"
+	contentTypeHeader     = "Content-Type"
+	contentLengthHeader   = "Content-Length"
+	varyHeader            = "Vary"
+
+	defaultGzipMinSize = 1024
+)
+
+// Content types compressed by default
+var defaultGzipContentTypes = []string{
+	"application/json",
+	"application/javascript",
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/xml",
+}
+
+// GzipConfig configuration for Gzip middleware
+type GzipConfig struct {
+	// Minimal size of response body in bytes which will be compressed.
+	// If MinSize is empty that will be use default value - 1024 bytes.
+	MinSize int
+
+	// Compression level from compress/gzip.
+	// If Level is empty or invalid that will be use gzip.DefaultCompression.
+	Level int
+
+	// Media types of responses which will be compressed.
+	// If ContentTypes is empty that will be use default list with text and json types.
+	ContentTypes []string
+}
+
+// Gzip decompresses request bodies with header "Content-Encoding: gzip"
+// and compresses responses for clients which send "Accept-Encoding: gzip"
+func Gzip(c *GzipConfig) func(http.Handler) http.Handler {
+	if c.MinSize <= 0 {
+		c.MinSize = defaultGzipMinSize
+	}
+
+	if c.Level == 0 || c.Level < gzip.HuffmanOnly || c.Level > gzip.BestCompression {
+		c.Level = gzip.DefaultCompression
+	}
+
+	if len(c.ContentTypes) == 0 {
+		c.ContentTypes = defaultGzipContentTypes
+	}
+
+	pool := &sync.Pool{
+		New: func() interface{} {
+			// Level was checked above, so error is not expected here
+			gz, err := gzip.NewWriterLevel(io.Discard, c.Level)
+			if err != nil {
+				gz = gzip.NewWriter(io.Discard)
+			}
+
+			return gz
+		},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hasEncoding(r.Header.Get(contentEncodingHeader), encodingGzip) {
+				gr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					log.Printf("unable to decompress request body. Error: %s\n", err)
+					http.Error(w, "unable to decompress request body", http.StatusBadRequest)
+
+					return
+				}
+
+				defer func() {
+					_ = gr.Close()
+				}()
+
+				r.Body = gr
+				r.ContentLength = -1
+				r.Header.Del(contentEncodingHeader)
+				r.Header.Del(contentLengthHeader)
+			}
+
+			if !hasEncoding(r.Header.Get(acceptEncodingHeader), encodingGzip) {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			gw := &gzipResponseWriter{
+				ResponseWriter: w,
+				c:              c,
+				pool:           pool,
+				status:         http.StatusOK,
+			}
+
+			defer gw.Close()
+
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// gzipResponseWriter buffers beginning of response
+// until it knows whether response must be compressed
+type gzipResponseWriter struct {
+	http.ResponseWriter
+
+	c    *GzipConfig
+	pool *sync.Pool
+	gz   *gzip.Writer
+
+	buf     []byte
+	status  int
+	decided bool
+}
+
+// WriteHeader remembers status code. Headers will be sent with first part of body
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.decided {
+		return
+	}
+
+	w.status = status
+}
+
+// Write buffers data until MinSize and after that writing with or without compression
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+
+		if len(w.buf) < w.c.MinSize {
+			return len(p), nil
+		}
+
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+
+		return len(p), nil
+	}
+
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// Close flushes buffered data and returns gzip writer to pool
+func (w *gzipResponseWriter) Close() {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			log.Printf("unable to write response. Error: %s\n", err)
+		}
+	}
+
+	if w.gz == nil {
+		return
+	}
+
+	if err := w.gz.Close(); err != nil {
+		log.Printf("unable to finish gzip stream. Error: %s\n", err)
+	}
+
+	w.gz.Reset(io.Discard)
+	w.pool.Put(w.gz)
+	w.gz = nil
+}
+
+// decide choosing compression, sending headers and buffered data
+func (w *gzipResponseWriter) decide() error {
+	w.decided = true
+
+	h := w.Header()
+
+	if len(w.buf) > 0 && h.Get(contentTypeHeader) == "" {
+		h.Set(contentTypeHeader, http.DetectContentType(w.buf))
+	}
+
+	compress := len(w.buf) >= w.c.MinSize &&
+		h.Get(contentEncodingHeader) == "" &&
+		bodyAllowed(w.status) &&
+		isCompressible(h.Get(contentTypeHeader), w.c.ContentTypes)
+
+	if !compress {
+		w.ResponseWriter.WriteHeader(w.status)
+
+		if len(w.buf) == 0 {
+			return nil
+		}
+
+		_, err := w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+
+		return err
+	}
+
+	h.Set(contentEncodingHeader, encodingGzip)
+	h.Add(varyHeader, acceptEncodingHeader)
+	h.Del(contentLengthHeader)
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.gz = w.pool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+
+	_, err := w.gz.Write(w.buf)
+	w.buf = nil
+
+	return err
+}
+
+// hasEncoding checking that header value with list of encodings contains encoding
+func hasEncoding(header, encoding string) bool {
+	for _, v := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(v), ";")
+
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+
+		// Client can refuse encoding with q=0
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+
+	return false
+}
+
+// isCompressible checking media type of content type header by list
+func isCompressible(contentType string, types []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range types {
+		if strings.EqualFold(mediaType, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bodyAllowed reports whether a given response status code permits a body
+func bodyAllowed(status int) bool {
+	if status >= 100 && status <= 199 {
+		return false
+	}
+
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
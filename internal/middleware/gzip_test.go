@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipDecompressRequest(t *testing.T) {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(`{"id":"Alloc"}`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	var got string
+
+	h := Gzip(&GzipConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		got = string(b)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/update/", &buf)
+	req.Header.Set(contentEncodingHeader, encodingGzip)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, `{"id":"Alloc"}`, got)
+}
+
+func TestGzipCompressResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		compressed  bool
+	}{
+		{
+			name:        "large json",
+			body:        strings.Repeat("a", 2048),
+			contentType: "application/json",
+			compressed:  true,
+		},
+		{
+			name:        "small json",
+			body:        "{}",
+			contentType: "application/json",
+			compressed:  false,
+		},
+		{
+			name:        "large binary",
+			body:        strings.Repeat("a", 2048),
+			contentType: "application/octet-stream",
+			compressed:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Gzip(&GzipConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(contentTypeHeader, tt.contentType)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(acceptEncodingHeader, "gzip, deflate")
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			if !tt.compressed {
+				require.Empty(t, rec.Header().Get(contentEncodingHeader))
+				require.Equal(t, tt.body, rec.Body.String())
+
+				return
+			}
+
+			require.Equal(t, encodingGzip, rec.Header().Get(contentEncodingHeader))
+
+			gz, err := gzip.NewReader(rec.Body)
+			require.NoError(t, err)
+
+			b, err := io.ReadAll(gz)
+			require.NoError(t, err)
+			require.Equal(t, tt.body, string(b))
+		})
+	}
+}
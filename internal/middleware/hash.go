@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/mtrrun/internal/sign"
+)
+
+// HashConfig configuration for Hash middleware
+type HashConfig struct {
+	// Shared key of server and agents. If Key is empty middleware does nothing
+	Key string
+}
+
+// Hash verifies HMAC-SHA256 of request body from header "HashSHA256"
+// and signs response body with the same key.
+// Requests which change metrics, i.e. with any method except GET, HEAD and OPTIONS,
+// must be signed. Reading requests are checked only if they contain header.
+// Request without body is signed by its URI.
+func Hash(c *HashConfig) func(http.Handler) http.Handler {
+	key := []byte(c.Key)
+
+	return func(next http.Handler) http.Handler {
+		if len(key) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sum := r.Header.Get(sign.Header)
+
+			if sum != "" || !isSafeMethod(r.Method) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					log.Printf("unable to read request body. Error: %s\n", err)
+					http.Error(w, "unable to read request body", http.StatusBadRequest)
+
+					return
+				}
+
+				r.Body = io.NopCloser(bytes.NewReader(body))
+
+				data := body
+				if len(data) == 0 {
+					data = []byte(r.URL.RequestURI())
+				}
+
+				if !sign.Verify(key, data, sum) {
+					log.Printf("invalid signature of request to %s from %s\n", r.URL.Path, caller(r))
+					http.Error(w, "invalid signature", http.StatusBadRequest)
+
+					return
+				}
+			}
+
+			hw := &hashResponseWriter{
+				ResponseWriter: w,
+				status:         http.StatusOK,
+			}
+
+			next.ServeHTTP(hw, r)
+
+			w.Header().Set(sign.Header, sign.Sum(key, hw.buf.Bytes()))
+			w.WriteHeader(hw.status)
+
+			if _, err := w.Write(hw.buf.Bytes()); err != nil {
+				log.Printf("unable to write response. Error: %s\n", err)
+			}
+		})
+	}
+}
+
+// isSafeMethod reports whether request with method only reads data
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// hashResponseWriter buffers whole response for signing
+type hashResponseWriter struct {
+	http.ResponseWriter
+
+	buf    bytes.Buffer
+	status int
+}
+
+// WriteHeader remembers status code. It will be sent with signed body
+func (w *hashResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+// Write buffers body for signing
+func (w *hashResponseWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mtrrun/internal/sign"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	key, body := "secret", `{"id":"Alloc","type":"gauge","value":1}`
+
+	tests := []struct {
+		name   string
+		sum    string
+		status int
+	}{
+		{
+			name:   "valid signature",
+			sum:    sign.Sum([]byte(key), []byte(body)),
+			status: http.StatusOK,
+		},
+		{
+			name:   "invalid signature",
+			sum:    sign.Sum([]byte("other"), []byte(body)),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "without signature",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	h := Hash(&HashConfig{Key: key})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader(body))
+			if tt.sum != "" {
+				req.Header.Set(sign.Header, tt.sum)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+
+			if tt.status == http.StatusOK {
+				require.True(t, sign.Verify([]byte(key), rec.Body.Bytes(), rec.Header().Get(sign.Header)))
+			}
+		})
+	}
+}
+
+func TestHashWithoutBody(t *testing.T) {
+	key, uri := "secret", "/value/gauge/Alloc"
+
+	tests := []struct {
+		name   string
+		method string
+		sum    string
+		status int
+	}{
+		{name: "signed delete", method: http.MethodDelete, sum: sign.Sum([]byte(key), []byte(uri)), status: http.StatusOK},
+		{name: "unsigned delete", method: http.MethodDelete, status: http.StatusBadRequest},
+		{name: "delete signed by other key", method: http.MethodDelete, sum: sign.Sum([]byte("other"), []byte(uri)), status: http.StatusBadRequest},
+		{name: "unsigned read", method: http.MethodGet, status: http.StatusOK},
+		{name: "read with invalid signature", method: http.MethodGet, sum: "invalid", status: http.StatusBadRequest},
+	}
+
+	h := Hash(&HashConfig{Key: key})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, uri, nil)
+			if tt.sum != "" {
+				req.Header.Set(sign.Header, tt.sum)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type identityKey struct{}
+
+// Identity puts identity of agent from verified client certificate into
+// request context. Identity is common name of certificate subject or
+// first DNS name if common name is empty.
+// Identity is caller in logs of rejected requests when request has no token.
+// Requests without client certificate pass as is.
+func Identity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		cert := r.TLS.VerifiedChains[0][0]
+
+		id := cert.Subject.CommonName
+		if id == "" && len(cert.DNSNames) > 0 {
+			id = cert.DNSNames[0]
+		}
+
+		if id == "" {
+			http.Error(w, "client certificate has no subject", http.StatusForbidden)
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
+
+// IdentityFromContext returning identity of agent which was put by Identity
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(identityKey{}).(string)
+
+	return id, ok
+}
+
+// caller returning client of request for logs: name of token which was put by Auth,
+// identity from client certificate or peer address if there are no credentials
+func caller(r *http.Request) string {
+	if t, ok := TokenFromContext(r.Context()); ok {
+		return fmt.Sprintf("token %q", t.Name)
+	}
+
+	if id, ok := IdentityFromContext(r.Context()); ok {
+		return fmt.Sprintf("agent %q", id)
+	}
+
+	return r.RemoteAddr
+}
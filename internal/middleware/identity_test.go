@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newClientCert creating self-signed client certificate with common name and DNS names
+func newClientCert(t *testing.T, cn string, dnsNames ...string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              dnsNames,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestIdentity(t *testing.T) {
+	byName := newClientCert(t, "agent-1")
+	byDNS := newClientCert(t, "", "agent-2.local")
+	anonymous := newClientCert(t, "")
+
+	pool := x509.NewCertPool()
+	for _, c := range []tls.Certificate{byName, byDNS, anonymous} {
+		pool.AddCert(c.Leaf)
+	}
+
+	srv := httptest.NewUnstartedServer(Identity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := IdentityFromContext(r.Context())
+		if !ok {
+			id = "none"
+		}
+
+		_, _ = io.WriteString(w, id)
+	})))
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  pool,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		certs  []tls.Certificate
+		status int
+		want   string
+	}{
+		{name: "common name", certs: []tls.Certificate{byName}, status: http.StatusOK, want: "agent-1"},
+		{name: "dns name", certs: []tls.Certificate{byDNS}, status: http.StatusOK, want: "agent-2.local"},
+		{name: "without certificate", status: http.StatusOK, want: "none"},
+		{name: "without subject", certs: []tls.Certificate{anonymous}, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := srv.Client()
+			transport := client.Transport.(*http.Transport).Clone()
+			transport.TLSClientConfig.Certificates = tt.certs
+			client.Transport = transport
+
+			resp, err := client.Get(srv.URL)
+			require.NoError(t, err)
+
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.status, resp.StatusCode)
+
+			if tt.want != "" {
+				require.Equal(t, tt.want, string(body))
+			}
+		})
+	}
+}
+
+func TestCaller(t *testing.T) {
+	cert := newClientCert(t, "agent-1")
+
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		want  string
+	}{
+		{name: "peer address", want: "10.0.0.1:1234"},
+		{name: "client certificate", state: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert.Leaf}}}, want: `agent "agent-1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.0.0.1:1234"
+			r.TLS = tt.state
+
+			var got string
+
+			Identity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = caller(r)
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
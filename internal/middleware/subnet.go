@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	realIPHeader       = "X-Real-IP"
+	forwardedForHeader = "X-Forwarded-For"
+)
+
+// TrustedSubnetConfig configuration for TrustedSubnet middleware
+type TrustedSubnetConfig struct {
+	// Subnets allowed to access endpoints. If Subnets is empty middleware does nothing
+	Subnets []*net.IPNet
+
+	// Addresses of reverse proxies which are allowed to pass
+	// address of client in headers "X-Forwarded-For" and "X-Real-IP"
+	TrustedProxies []*net.IPNet
+}
+
+// TrustedSubnet rejects requests with status 403
+// if address of client is not in trusted subnets.
+//
+// Address of client is taken from:
+//   - header "X-Forwarded-For" if request came from trusted proxy.
+//     The rightmost address which is not a trusted proxy is used;
+//   - header "X-Real-IP" if request came from trusted proxy;
+//   - peer address of connection.
+//
+// Headers from other peers are ignored, else any client could send trusted address in them
+func TrustedSubnet(c *TrustedSubnetConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(c.Subnets) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r, c.TrustedProxies)
+
+			if ip == nil || !containsIP(c.Subnets, ip) {
+				log.Printf("request to %s from untrusted address %s\n", r.URL.Path, ip)
+				http.Error(w, "forbidden", http.StatusForbidden)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ParseCIDRs parsing list of subnets in CIDR notation.
+// Single address is parsed as subnet with one address
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(list))
+
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, subnet)
+	}
+
+	return result, nil
+}
+
+// clientIP returning address of client for request
+func clientIP(r *http.Request, proxies []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	peer := net.ParseIP(host)
+
+	if peer == nil || !containsIP(proxies, peer) {
+		return peer
+	}
+
+	forwarded := strings.Split(r.Header.Get(forwardedForHeader), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+
+		if !containsIP(proxies, ip) {
+			return ip
+		}
+	}
+
+	if v := r.Header.Get(realIPHeader); v != "" {
+		if ip := net.ParseIP(strings.TrimSpace(v)); ip != nil {
+			return ip
+		}
+	}
+
+	return peer
+}
+
+func containsIP(subnets []*net.IPNet, ip net.IP) bool {
+	for _, n := range subnets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
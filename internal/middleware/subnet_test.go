@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedSubnet(t *testing.T) {
+	subnets, err := ParseCIDRs([]string{"192.168.1.0/24"})
+	require.NoError(t, err)
+
+	proxies, err := ParseCIDRs([]string{"10.0.0.1"})
+	require.NoError(t, err)
+
+	h := TrustedSubnet(&TrustedSubnetConfig{
+		Subnets:        subnets,
+		TrustedProxies: proxies,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name      string
+		peer      string
+		realIP    string
+		forwarded string
+		status    int
+	}{
+		{
+			name:   "peer in subnet",
+			peer:   "192.168.1.10:5000",
+			status: http.StatusOK,
+		},
+		{
+			name:   "peer out of subnet",
+			peer:   "172.16.0.1:5000",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "real ip from untrusted peer",
+			peer:   "172.16.0.1:5000",
+			realIP: "192.168.1.10",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "real ip from trusted proxy",
+			peer:   "10.0.0.1:5000",
+			realIP: "192.168.1.10",
+			status: http.StatusOK,
+		},
+		{
+			name:   "untrusted real ip from trusted proxy",
+			peer:   "10.0.0.1:5000",
+			realIP: "172.16.0.1",
+			status: http.StatusForbidden,
+		},
+		{
+			name:      "forwarded by trusted proxy",
+			peer:      "10.0.0.1:5000",
+			forwarded: "172.16.0.1, 192.168.1.10",
+			status:    http.StatusOK,
+		},
+		{
+			name:      "forwarded by untrusted proxy",
+			peer:      "10.0.0.2:5000",
+			forwarded: "192.168.1.10",
+			status:    http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/update/", nil)
+			req.RemoteAddr = tt.peer
+
+			if tt.realIP != "" {
+				req.Header.Set(realIPHeader, tt.realIP)
+			}
+
+			if tt.forwarded != "" {
+				req.Header.Set(forwardedForHeader, tt.forwarded)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
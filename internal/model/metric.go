@@ -44,3 +44,14 @@ type GetAllDTO struct {
 	Name  string
 	Value string
 }
+
+// Metrics data transfer object for JSON API.
+// Delta is filled for counter, Value is filled for gauge.
+// Labels are static labels of agent in pull mode. In push mode they are added to ID
+type Metrics struct {
+	ID     string            `json:"id"`
+	MType  string            `json:"type"`
+	Delta  *int64            `json:"delta,omitempty"`
+	Value  *float64          `json:"value,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
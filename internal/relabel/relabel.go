@@ -0,0 +1,210 @@
+// Package relabel changes names of metrics before they are reported:
+// filters them by regular expressions, renames them and adds prefix.
+// Also it keeps static labels which are attached to every metric
+package relabel
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Actions of rules
+const (
+	// ActionKeep drops metrics which names don't match regex
+	ActionKeep = "keep"
+	// ActionDrop drops metrics which names match regex
+	ActionDrop = "drop"
+	// ActionReplace renames metrics which names match regex to replacement.
+	// Replacement could contain capture groups, e.g. "${1}" or "${name}" like in regexp.Expand
+	ActionReplace = "replace"
+)
+
+// labelName is valid name of label in Prometheus
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Rule for names of metrics. Regex must match whole name
+type Rule struct {
+	Action      string `yaml:"action"`
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+// Config configuration list for Relabeler
+type Config struct {
+	// Rules are applied in order, metric which is dropped by rule is not checked by next rules.
+	// If metrics of the same type get the same name, agent keeps metric with the least original name
+	Rules []Rule `yaml:"rules"`
+
+	// Prefix is added to names after rules
+	Prefix string `yaml:"prefix"`
+
+	// Labels attached to every metric, e.g. hostname and environment
+	Labels map[string]string `yaml:"labels"`
+}
+
+// Read reading configuration from YAML file and validating it
+func Read(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+
+	if err = yaml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+
+	if _, err = New(c); err != nil {
+		return nil, fmt.Errorf("invalid relabel config %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+type rule struct {
+	action      string
+	regex       *regexp.Regexp
+	replacement string
+}
+
+// Relabeler applying rules to names of metrics. It is safe for concurrent use
+type Relabeler struct {
+	rules  []rule
+	prefix string
+	labels map[string]string
+}
+
+// New constructor for Relabeler. Returns error if any rule or label is invalid
+func New(c *Config) (*Relabeler, error) {
+	r := &Relabeler{
+		rules:  make([]rule, 0, len(c.Rules)),
+		prefix: c.Prefix,
+		labels: make(map[string]string, len(c.Labels)),
+	}
+
+	for i, cr := range c.Rules {
+		if cr.Regex == "" {
+			return nil, fmt.Errorf("rule %d: regex is empty", i+1)
+		}
+
+		// Regex is anchored like in Prometheus, so "go_.*" doesn't match "x_go_y"
+		re, err := regexp.Compile("^(?:" + cr.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+
+		switch cr.Action {
+		case ActionKeep, ActionDrop:
+		case ActionReplace:
+			if cr.Replacement == "" {
+				return nil, fmt.Errorf("rule %d: replacement is empty", i+1)
+			}
+		default:
+			return nil, fmt.Errorf("rule %d: unknown action %q. Expected %s, %s or %s", i+1, cr.Action, ActionKeep, ActionDrop, ActionReplace)
+		}
+
+		r.rules = append(r.rules, rule{
+			action:      cr.Action,
+			regex:       re,
+			replacement: cr.Replacement,
+		})
+	}
+
+	for name, value := range c.Labels {
+		if !labelName.MatchString(name) {
+			return nil, fmt.Errorf("invalid name of label %q", name)
+		}
+
+		r.labels[name] = value
+	}
+
+	return r, nil
+}
+
+// Apply returning new name of metric and false if metric is dropped.
+// Nil Relabeler returns name without changes
+func (r *Relabeler) Apply(name string) (string, bool) {
+	if r == nil {
+		return name, true
+	}
+
+	for _, rl := range r.rules {
+		matched := rl.regex.MatchString(name)
+
+		switch rl.action {
+		case ActionKeep:
+			if !matched {
+				return "", false
+			}
+		case ActionDrop:
+			if matched {
+				return "", false
+			}
+		case ActionReplace:
+			if matched {
+				name = rl.regex.ReplaceAllString(name, rl.replacement)
+			}
+		}
+
+		// Replacement with unknown capture groups gives empty name
+		if name == "" {
+			return "", false
+		}
+	}
+
+	return r.prefix + name, true
+}
+
+// Labels returning static labels. Result must not be changed
+func (r *Relabeler) Labels() map[string]string {
+	if r == nil {
+		return nil
+	}
+
+	return r.labels
+}
+
+// LabelNames returning sorted names of static labels
+func (r *Relabeler) LabelNames() []string {
+	if r == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(r.labels))
+	for name := range r.labels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Series returning name of metric with static labels sorted by name in format of Graphite tags,
+// e.g. "Alloc;env=prod;host=a", for servers which key metrics only by name.
+// Character ";" in values is replaced by "_". Name is not changed if there are no labels
+func (r *Relabeler) Series(name string) string {
+	names := r.LabelNames()
+	if len(names) == 0 {
+		return name
+	}
+
+	var b strings.Builder
+
+	b.WriteString(name)
+
+	for _, label := range names {
+		b.WriteByte(';')
+		b.WriteString(label)
+		b.WriteByte('=')
+		b.WriteString(strings.ReplaceAll(r.labels[label], ";", "_"))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package relabel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	r, err := New(&Config{
+		Rules: []Rule{
+			{Action: ActionDrop, Regex: "go_godebug_.*"},
+			{Action: ActionKeep, Regex: "go_.*|Alloc|CPUutilization[0-9]+"},
+			{Action: ActionReplace, Regex: "go_(.*)_bytes", Replacement: "${1}Bytes"},
+			{Action: ActionReplace, Regex: "CPUutilization([0-9]+)", Replacement: "cpu_${1}_utilization"},
+		},
+		Prefix: "app_",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		expected string
+		keep     bool
+	}{
+		{name: "Alloc", expected: "app_Alloc", keep: true},
+		{name: "go_gc_heap_goal_bytes", expected: "app_gc_heap_goalBytes", keep: true},
+		{name: "go_sched_goroutines_goroutines", expected: "app_go_sched_goroutines_goroutines", keep: true},
+		{name: "CPUutilization12", expected: "app_cpu_12_utilization", keep: true},
+		{name: "go_godebug_non_default_behavior_execerrdot_events", keep: false},
+		{name: "PollCount", keep: false},
+		// Regex must match whole name
+		{name: "x_go_y", keep: false},
+	}
+
+	for _, tt := range tests {
+		name, keep := r.Apply(tt.name)
+		require.Equal(t, tt.keep, keep, tt.name)
+		require.Equal(t, tt.expected, name, tt.name)
+	}
+
+	var empty *Relabeler
+
+	name, keep := empty.Apply("Alloc")
+	require.True(t, keep)
+	require.Equal(t, "Alloc", name)
+}
+
+func TestSeries(t *testing.T) {
+	r, err := New(&Config{Labels: map[string]string{"host": "a", "env": "prod;eu"}})
+	require.NoError(t, err)
+	require.Equal(t, "Alloc;env=prod_eu;host=a", r.Series("Alloc"))
+
+	r, err = New(&Config{Prefix: "app_"})
+	require.NoError(t, err)
+	require.Equal(t, "Alloc", r.Series("Alloc"))
+
+	var empty *Relabeler
+
+	require.Equal(t, "Alloc", empty.Series("Alloc"))
+}
+
+func TestNewValidates(t *testing.T) {
+	for _, c := range []*Config{
+		{Rules: []Rule{{Action: "rename", Regex: ".*"}}},
+		{Rules: []Rule{{Action: ActionKeep}}},
+		{Rules: []Rule{{Action: ActionDrop, Regex: "("}}},
+		{Rules: []Rule{{Action: ActionReplace, Regex: ".*"}}},
+		{Labels: map[string]string{"1host": "a"}},
+		{Labels: map[string]string{"host-name": "a"}},
+	} {
+		_, err := New(c)
+		require.Error(t, err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relabel.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+rules:
+  - action: drop
+    regex: "Random.*"
+prefix: app_
+labels:
+  env: prod
+`), 0o600))
+
+	c, err := Read(path)
+	require.NoError(t, err)
+
+	r, err := New(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"env": "prod"}, r.Labels())
+
+	_, keep := r.Apply("RandomValue")
+	require.False(t, keep)
+
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - action: keep\n"), 0o600))
+
+	_, err = Read(path)
+	require.Error(t, err)
+}
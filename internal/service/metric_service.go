@@ -207,3 +207,67 @@ func (s *MetricService) GetAll(ctx context.Context) ([]model.GetAllDTO, error) {
 
 	return result, nil
 }
+
+// GetGauges return all gauge metrics
+func (s *MetricService) GetGauges(ctx context.Context) ([]model.GetGaugeDTO, error) {
+	data, err := s.metRepo.SelectGauge(ctx)
+
+	if err != nil {
+		log.Println("unable to find all gauge metrics")
+
+		return nil, err
+	}
+
+	result := make([]model.GetGaugeDTO, 0, len(data))
+
+	for i := 0; i < len(data); i++ {
+		result = append(result, model.GetGaugeDTO(data[i]))
+	}
+
+	return result, nil
+}
+
+// GetCounters return all counter metrics
+func (s *MetricService) GetCounters(ctx context.Context) ([]model.GetCounterDTO, error) {
+	data, err := s.metRepo.SelectCounter(ctx)
+
+	if err != nil {
+		log.Println("unable to find all counter metrics")
+
+		return nil, err
+	}
+
+	result := make([]model.GetCounterDTO, 0, len(data))
+
+	for i := 0; i < len(data); i++ {
+		result = append(result, model.GetCounterDTO(data[i]))
+	}
+
+	return result, nil
+}
+
+// DeleteGauge calling data layer for removing gauge metric. Error is returned if metric not exists
+func (s *MetricService) DeleteGauge(ctx context.Context, name string) error {
+	if err := s.metRepo.DeleteGauge(ctx, name); err != nil {
+		log.Printf("metric with type=gauge and name=%s was not deleted\n", name)
+
+		return err
+	}
+
+	log.Printf("metric with type=gauge and name=%s deleted\n", name)
+
+	return nil
+}
+
+// DeleteCounter calling data layer for removing counter metric. Error is returned if metric not exists
+func (s *MetricService) DeleteCounter(ctx context.Context, name string) error {
+	if err := s.metRepo.DeleteCounter(ctx, name); err != nil {
+		log.Printf("metric with type=counter and name=%s was not deleted\n", name)
+
+		return err
+	}
+
+	log.Printf("metric with type=counter and name=%s deleted\n", name)
+
+	return nil
+}
@@ -0,0 +1,33 @@
+// Package sign have HMAC-SHA256 signing of payloads
+// between agent and server
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Header contains hex encoded HMAC-SHA256 of payload
+const Header = "HashSHA256"
+
+// Sum returning hex encoded HMAC-SHA256 of data with key
+func Sum(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checking that sum is valid HMAC-SHA256 of data with key
+func Verify(key, data []byte, sum string) bool {
+	expected, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,18 @@
+package sign
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	key, data := []byte("secret"), []byte(`{"id":"Alloc","type":"gauge","value":1}`)
+
+	sum := Sum(key, data)
+
+	require.True(t, Verify(key, data, sum))
+	require.False(t, Verify([]byte("other"), data, sum))
+	require.False(t, Verify(key, []byte(`{}`), sum))
+	require.False(t, Verify(key, data, "not hex"))
+}
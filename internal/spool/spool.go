@@ -0,0 +1,507 @@
+// Package spool have persistent FIFO queue on disk.
+//
+// Queue is stored in directory as segmented append-only files.
+// Every record in segment has layout:
+//
+//	[4 bytes: length of payload][payload][4 bytes: CRC-32 of payload]
+//
+// Read position is stored in separate file, so records which were
+// acknowledged are not returned again after restart. Torn record at
+// the end of last segment after crash is cut off on open.
+//
+// Queue is limited by total size and by age of segments. When limit
+// is reached the oldest segments are dropped.
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentExt = ".seg"
+	offsetFile = "offset"
+
+	frameHeaderSize  = 4
+	frameTrailerSize = 4
+
+	defaultMaxSegmentSize = 1 << 20
+	defaultMaxSize        = 64 << 20
+)
+
+var errCorrupted = errors.New("corrupted record")
+
+// Config configuration list for Queue
+type Config struct {
+	// Directory for segments. It is created if not exists
+	Dir string
+
+	// If MaxSegmentSize is empty that will be use default value - 1 MiB.
+	MaxSegmentSize int64
+
+	// Max size of all segments. If MaxSize is empty that will be use default value - 64 MiB.
+	MaxSize int64
+
+	// Segments older than MaxAge are dropped. If MaxAge is empty age is not limited
+	MaxAge time.Duration
+}
+
+type segment struct {
+	seq     uint64
+	size    int64
+	modTime time.Time
+}
+
+// Queue is persistent FIFO queue on disk
+type Queue struct {
+	mu sync.Mutex
+
+	c Config
+
+	// Segments sorted from oldest to newest. Last one is opened for append
+	segments []*segment
+	active   *os.File
+
+	// Read position
+	readSeq uint64
+	readOff int64
+
+	// Size of frame returned by last Peek
+	peeked int64
+
+	// Sequence number of next segment. It never decreases,
+	// so new segments are always after read position
+	nextSeq uint64
+}
+
+// Open constructor for Queue. Loads segments which were left in directory
+func Open(c *Config) (*Queue, error) {
+	if c.MaxSegmentSize <= 0 {
+		c.MaxSegmentSize = defaultMaxSegmentSize
+	}
+
+	if c.MaxSize <= 0 {
+		c.MaxSize = defaultMaxSize
+	}
+
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	q := &Queue{
+		c: *c,
+	}
+
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Append adding record to the end of queue
+func (q *Queue) Append(payload []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.trimAge()
+
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload)+frameTrailerSize)
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	frame = append(frame, payload...)
+	frame = frame[:len(frame)+frameTrailerSize]
+	binary.BigEndian.PutUint32(frame[len(frame)-frameTrailerSize:], crc32.ChecksumIEEE(payload))
+
+	last := q.last()
+
+	if last == nil || (last.size > 0 && last.size+int64(len(frame)) > q.c.MaxSegmentSize) {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+
+		last = q.last()
+	}
+
+	if _, err := q.active.Write(frame); err != nil {
+		return err
+	}
+
+	if err := q.active.Sync(); err != nil {
+		return err
+	}
+
+	last.size += int64(len(frame))
+	last.modTime = time.Now()
+
+	q.trimSize()
+
+	return nil
+}
+
+// Peek returning the oldest record without removing it.
+// Returns false if queue is empty
+func (q *Queue) Peek() ([]byte, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.trimAge()
+
+	for len(q.segments) > 0 {
+		seg := q.segments[0]
+
+		// Segment was read before restart, but was not removed
+		if seg.seq < q.readSeq && seg != q.last() {
+			q.removeFirst()
+
+			continue
+		}
+
+		if q.readSeq != seg.seq {
+			q.readSeq, q.readOff = seg.seq, 0
+		}
+
+		if q.readOff >= seg.size {
+			if seg == q.last() {
+				return nil, false, nil
+			}
+
+			q.removeFirst()
+
+			continue
+		}
+
+		payload, err := q.readFrame(seg, q.readOff)
+		if errors.Is(err, errCorrupted) && seg != q.last() {
+			log.Printf("skipping rest of corrupted segment %s\n", q.path(seg.seq))
+			q.removeFirst()
+
+			continue
+		}
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		q.peeked = int64(frameHeaderSize + len(payload) + frameTrailerSize)
+
+		return payload, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// Ack removing record which was returned by last Peek
+func (q *Queue) Ack() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.peeked == 0 || len(q.segments) == 0 {
+		return nil
+	}
+
+	q.readOff += q.peeked
+	q.peeked = 0
+
+	seg := q.segments[0]
+
+	if q.readOff >= seg.size && seg != q.last() {
+		q.removeFirst()
+
+		return nil
+	}
+
+	return q.saveOffset()
+}
+
+// Empty reports whether queue has no records
+func (q *Queue) Empty() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.segments) == 0 {
+		return true
+	}
+
+	seg := q.segments[0]
+
+	return len(q.segments) == 1 && q.readSeq == seg.seq && q.readOff >= seg.size
+}
+
+// Close closing segment opened for append
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.active == nil {
+		return nil
+	}
+
+	err := q.active.Close()
+	q.active = nil
+
+	return err
+}
+
+func (q *Queue) load() error {
+	entries, err := os.ReadDir(q.c.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentExt) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), segmentExt), 16, 64)
+		if err != nil {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+
+		q.segments = append(q.segments, &segment{
+			seq:     seq,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(q.segments, func(i, j int) bool {
+		return q.segments[i].seq < q.segments[j].seq
+	})
+
+	q.loadOffset()
+
+	q.nextSeq = q.readSeq + 1
+
+	last := q.last()
+	if last == nil {
+		return nil
+	}
+
+	if last.seq >= q.nextSeq {
+		q.nextSeq = last.seq + 1
+	}
+
+	if err = q.repair(last); err != nil {
+		return err
+	}
+
+	if q.readSeq == last.seq && q.readOff > last.size {
+		q.readOff = last.size
+	}
+
+	q.active, err = os.OpenFile(q.path(last.seq), os.O_WRONLY|os.O_APPEND, 0o600)
+
+	return err
+}
+
+// repair cutting off torn record at the end of segment
+func (q *Queue) repair(seg *segment) error {
+	var off int64
+
+	for off < seg.size {
+		payload, err := q.readFrame(seg, off)
+		if err != nil {
+			break
+		}
+
+		off += int64(frameHeaderSize + len(payload) + frameTrailerSize)
+	}
+
+	if off == seg.size {
+		return nil
+	}
+
+	log.Printf("cutting off torn record at the end of segment %s\n", q.path(seg.seq))
+
+	seg.size = off
+
+	return os.Truncate(q.path(seg.seq), off)
+}
+
+func (q *Queue) readFrame(seg *segment, off int64) ([]byte, error) {
+	f, err := os.Open(q.path(seg.seq))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	header := make([]byte, frameHeaderSize)
+
+	if _, err = f.ReadAt(header, off); err != nil {
+		return nil, fmt.Errorf("%w: %s", errCorrupted, err)
+	}
+
+	n := int64(binary.BigEndian.Uint32(header))
+
+	if off+frameHeaderSize+n+frameTrailerSize > seg.size {
+		return nil, errCorrupted
+	}
+
+	b := make([]byte, n+frameTrailerSize)
+
+	if _, err = f.ReadAt(b, off+frameHeaderSize); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w: %s", errCorrupted, err)
+	}
+
+	payload := b[:n]
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(b[n:]) {
+		return nil, errCorrupted
+	}
+
+	return payload, nil
+}
+
+// rotate creating new segment for append
+func (q *Queue) rotate() error {
+	seq := q.nextSeq
+	q.nextSeq++
+
+	if q.active != nil {
+		if err := q.active.Close(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(q.path(seq), os.O_WRONLY|os.O_CREATE|os.O_APPEND|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+
+	q.active = f
+	q.segments = append(q.segments, &segment{
+		seq:     seq,
+		modTime: time.Now(),
+	})
+
+	return nil
+}
+
+// trimSize dropping the oldest segments while queue is bigger than MaxSize
+func (q *Queue) trimSize() {
+	for len(q.segments) > 1 && q.size() > q.c.MaxSize {
+		log.Printf("spool is full, dropping the oldest segment %s\n", q.path(q.segments[0].seq))
+		q.removeFirst()
+	}
+}
+
+// trimAge dropping segments older than MaxAge
+func (q *Queue) trimAge() {
+	if q.c.MaxAge <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(-q.c.MaxAge)
+
+	for len(q.segments) > 0 && q.segments[0].modTime.Before(deadline) {
+		log.Printf("dropping expired segment %s\n", q.path(q.segments[0].seq))
+		q.removeFirst()
+	}
+}
+
+// removeFirst removing the oldest segment
+func (q *Queue) removeFirst() {
+	seg := q.segments[0]
+
+	if seg == q.last() && q.active != nil {
+		_ = q.active.Close()
+		q.active = nil
+	}
+
+	if err := os.Remove(q.path(seg.seq)); err != nil && !os.IsNotExist(err) {
+		log.Printf("unable to remove segment: %s\n", err)
+	}
+
+	q.segments = q.segments[1:]
+	q.peeked = 0
+
+	if len(q.segments) > 0 {
+		q.readSeq, q.readOff = q.segments[0].seq, 0
+	}
+
+	if err := q.saveOffset(); err != nil {
+		log.Printf("unable to save read position: %s\n", err)
+	}
+}
+
+func (q *Queue) size() int64 {
+	var total int64
+
+	for _, s := range q.segments {
+		total += s.size
+	}
+
+	return total
+}
+
+func (q *Queue) last() *segment {
+	if len(q.segments) == 0 {
+		return nil
+	}
+
+	return q.segments[len(q.segments)-1]
+}
+
+func (q *Queue) path(seq uint64) string {
+	return filepath.Join(q.c.Dir, fmt.Sprintf("%016x%s", seq, segmentExt))
+}
+
+// loadOffset reading read position. If it is missing or points
+// to removed segment reading starts from the oldest segment
+func (q *Queue) loadOffset() {
+	if len(q.segments) > 0 {
+		q.readSeq, q.readOff = q.segments[0].seq, 0
+	}
+
+	b, err := os.ReadFile(filepath.Join(q.c.Dir, offsetFile))
+	if err != nil {
+		return
+	}
+
+	var seq uint64
+	var off int64
+
+	if _, err = fmt.Sscanf(string(b), "%d %d", &seq, &off); err != nil {
+		return
+	}
+
+	for _, s := range q.segments {
+		if s.seq == seq && off <= s.size {
+			q.readSeq, q.readOff = seq, off
+
+			return
+		}
+	}
+}
+
+// saveOffset writing read position atomically
+func (q *Queue) saveOffset() error {
+	path := filepath.Join(q.c.Dir, offsetFile)
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", q.readSeq, q.readOff)), 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
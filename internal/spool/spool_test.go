@@ -0,0 +1,108 @@
+package spool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// drain reading and acknowledging all records
+func drain(t *testing.T, q *Queue) []string {
+	t.Helper()
+
+	result := make([]string, 0)
+
+	for {
+		b, ok, err := q.Peek()
+		require.NoError(t, err)
+
+		if !ok {
+			return result
+		}
+
+		result = append(result, string(b))
+		require.NoError(t, q.Ack())
+	}
+}
+
+func TestQueueOrderAndRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := Open(&Config{Dir: dir, MaxSegmentSize: 32})
+	require.NoError(t, err)
+	require.True(t, q.Empty())
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, q.Append([]byte(fmt.Sprintf("record-%d", i))))
+	}
+
+	require.False(t, q.Empty())
+
+	// Acknowledged records are not returned after restart
+	for i := 0; i < 3; i++ {
+		b, ok, err := q.Peek()
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, fmt.Sprintf("record-%d", i), string(b))
+		require.NoError(t, q.Ack())
+	}
+
+	// Record without Ack is returned again
+	b, ok, err := q.Peek()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "record-3", string(b))
+
+	require.NoError(t, q.Close())
+
+	q, err = Open(&Config{Dir: dir, MaxSegmentSize: 32})
+	require.NoError(t, err)
+
+	got := drain(t, q)
+	require.Equal(t, []string{"record-3", "record-4", "record-5", "record-6", "record-7", "record-8", "record-9"}, got)
+	require.True(t, q.Empty())
+
+	require.NoError(t, q.Append([]byte("after")))
+	require.Equal(t, []string{"after"}, drain(t, q))
+	require.NoError(t, q.Close())
+}
+
+func TestQueueDropsOldest(t *testing.T) {
+	q, err := Open(&Config{Dir: t.TempDir(), MaxSegmentSize: 20, MaxSize: 60})
+	require.NoError(t, err)
+
+	// Every record takes one segment with 18 bytes
+	for i := 0; i < 10; i++ {
+		require.NoError(t, q.Append([]byte(fmt.Sprintf("record-%03d", i))))
+	}
+
+	require.Equal(t, []string{"record-007", "record-008", "record-009"}, drain(t, q))
+}
+
+func TestQueueRepairsTornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := Open(&Config{Dir: dir})
+	require.NoError(t, err)
+
+	require.NoError(t, q.Append([]byte("first")))
+	require.NoError(t, q.Append([]byte("second")))
+	require.NoError(t, q.Close())
+
+	// Simulating crash in the middle of writing record
+	path := filepath.Join(dir, fmt.Sprintf("%016x%s", 1, segmentExt))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+
+	q, err = Open(&Config{Dir: dir})
+	require.NoError(t, err)
+
+	require.NoError(t, q.Append([]byte("third")))
+	require.Equal(t, []string{"first", "third"}, drain(t, q))
+	require.NoError(t, q.Close())
+}
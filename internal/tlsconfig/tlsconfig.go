@@ -0,0 +1,170 @@
+// Package tlsconfig builds TLS configuration for server and agent
+// from files with certificates and keys
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const defaultMinVersion = tls.VersionTLS12
+
+// ServerConfig configuration list for TLS of server
+type ServerConfig struct {
+	CertFile string
+	KeyFile  string
+
+	// Minimal TLS version: "1.0", "1.1", "1.2" or "1.3".
+	// If MinVersion is empty that will be use default value - "1.2".
+	MinVersion string
+
+	// Names of allowed cipher suites for TLS 1.0-1.2, e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+	// If CipherSuites is empty that will be use Go default list.
+	// Cipher suites of TLS 1.3 are not configurable.
+	CipherSuites []string
+
+	// Path to PEM file with CA certificates. If ClientCAFile is set
+	// server requires client certificates signed by this CA (mutual TLS)
+	ClientCAFile string
+}
+
+// ClientConfig configuration list for TLS of agent
+type ClientConfig struct {
+	// Path to PEM file with CA certificates for verifying server.
+	// If CAFile is empty that will be use system pool.
+	CAFile string
+
+	// Client certificate and key for mutual TLS
+	CertFile string
+	KeyFile  string
+
+	// Name of server in certificate if it differs from host in address
+	ServerName string
+}
+
+// Server returning TLS configuration for server.
+// Certificate is reloaded automatically when its files are changed.
+func Server(c *ServerConfig) (*tls.Config, error) {
+	reloader, err := newCertReloader(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	minVersion, err := parseVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	suites, err := parseCipherSuites(c.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if c.ClientCAFile != "" {
+		pool, err := readCertPool(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// Client returning TLS configuration for agent.
+// Client certificate is reloaded automatically when its files are changed.
+func Client(c *ClientConfig) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: defaultMinVersion,
+		ServerName: c.ServerName,
+	}
+
+	if c.CAFile != "" {
+		pool, err := readCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		reloader, err := newCertReloader(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.GetClientCertificate = reloader.GetClientCertificate
+	}
+
+	return cfg, nil
+}
+
+func readCertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates in %s", path)
+	}
+
+	return pool, nil
+}
+
+func parseVersion(v string) (uint16, error) {
+	switch v {
+	case "":
+		return defaultMinVersion, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS version %q. Expected: 1.0, 1.1, 1.2 or 1.3", v)
+	}
+}
+
+// parseCipherSuites mapping names to IDs. Only secure cipher suites are allowed
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	result := make([]uint16, 0, len(names))
+
+	for _, name := range names {
+		id, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+
+		result = append(result, id)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writing self-signed certificate with common name to files
+func writeCert(t *testing.T, certFile, keyFile, cn string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+
+	return parsed.Subject.CommonName
+}
+
+func TestServerReloadsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	writeCert(t, certFile, keyFile, "old")
+
+	cfg, err := Server(&ServerConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+
+	cert, err := cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "old", commonName(t, cert))
+
+	writeCert(t, certFile, keyFile, "new")
+
+	// Modification time could be the same on file systems with low precision
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+
+	cert, err = cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "new", commonName(t, cert))
+
+	// Broken files don't replace working certificate
+	broken := future.Add(time.Minute)
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	require.NoError(t, os.Chtimes(certFile, broken, broken))
+
+	cert, err = cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "new", commonName(t, cert))
+
+	// Files are not read again until their modification time changes
+	writeCert(t, certFile, keyFile, "fixed")
+	require.NoError(t, os.Chtimes(certFile, broken, broken))
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+
+	cert, err = cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "new", commonName(t, cert))
+
+	fixed := broken.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, fixed, fixed))
+
+	cert, err = cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "fixed", commonName(t, cert))
+}
+
+func TestServerInvalidOptions(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	writeCert(t, certFile, keyFile, "server")
+
+	_, err := Server(&ServerConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "2.0"})
+	require.Error(t, err)
+
+	_, err = Server(&ServerConfig{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}})
+	require.Error(t, err)
+}
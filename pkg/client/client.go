@@ -0,0 +1,277 @@
+// Package client is Go client for API of metrics server.
+// It supports path-based API for single values and JSON API.
+// Names with "/" could be used with JSON API only
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Types of metrics
+const (
+	TypeGauge   = "gauge"
+	TypeCounter = "counter"
+)
+
+const (
+	contentTypeHeader = "Content-Type"
+	contentTypeJSON   = "application/json"
+	contentTypeText   = "text/plain"
+)
+
+// Metric is metric in JSON API. Delta is filled for counter, Value is filled for gauge
+type Metric struct {
+	ID    string   `json:"id"`
+	MType string   `json:"type"`
+	Delta *int64   `json:"delta,omitempty"`
+	Value *float64 `json:"value,omitempty"`
+}
+
+// Gauge returning gauge metric with value
+func Gauge(name string, value float64) Metric {
+	return Metric{
+		ID:    name,
+		MType: TypeGauge,
+		Value: &value,
+	}
+}
+
+// Counter returning counter metric with delta which is added to value on server
+func Counter(name string, delta int64) Metric {
+	return Metric{
+		ID:    name,
+		MType: TypeCounter,
+		Delta: &delta,
+	}
+}
+
+// Config configuration list for Client
+type Config struct {
+	// Address of server, e.g. "127.0.0.1:8080" or "https://metrics.local".
+	// If scheme is empty that will be use http
+	Address string
+
+	// If HTTPClient is empty that will be use http.DefaultClient
+	HTTPClient *http.Client
+
+	// Hooks for every request in order, e.g. BearerToken and HMAC
+	Hooks []Hook
+}
+
+// Client for metrics server. It is safe for concurrent use
+type Client struct {
+	base  string
+	http  *http.Client
+	hooks []Hook
+}
+
+// New constructor for Client
+func New(c *Config) *Client {
+	base := strings.TrimSuffix(c.Address, "/")
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		base:  base,
+		http:  httpClient,
+		hooks: c.Hooks,
+	}
+}
+
+// UpdateGauge setting value of gauge with path-based API
+func (c *Client) UpdateGauge(ctx context.Context, name string, value float64) error {
+	_, err := c.do(ctx, http.MethodPost, c.path("update", TypeGauge, name, strconv.FormatFloat(value, 'f', -1, 64)), nil)
+
+	return err
+}
+
+// UpdateCounter adding delta to counter with path-based API
+func (c *Client) UpdateCounter(ctx context.Context, name string, delta int64) error {
+	_, err := c.do(ctx, http.MethodPost, c.path("update", TypeCounter, name, strconv.FormatInt(delta, 10)), nil)
+
+	return err
+}
+
+// GetGauge returning value of gauge with path-based API
+func (c *Client) GetGauge(ctx context.Context, name string) (float64, error) {
+	b, err := c.do(ctx, http.MethodGet, c.path("value", TypeGauge, name), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected value of gauge %s: %w", name, err)
+	}
+
+	return v, nil
+}
+
+// GetCounter returning value of counter with path-based API
+func (c *Client) GetCounter(ctx context.Context, name string) (int64, error) {
+	b, err := c.do(ctx, http.MethodGet, c.path("value", TypeCounter, name), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected value of counter %s: %w", name, err)
+	}
+
+	return v, nil
+}
+
+// Update creating or updating metric with JSON API. Returns actual state of metric
+func (c *Client) Update(ctx context.Context, m Metric) (Metric, error) {
+	var result Metric
+
+	err := c.doJSON(ctx, http.MethodPost, c.base+"/update/", m, &result)
+
+	return result, err
+}
+
+// Updates creating or updating list of metrics with JSON API.
+// Server rejects whole list if any metric is invalid
+func (c *Client) Updates(ctx context.Context, list []Metric) error {
+	return c.doJSON(ctx, http.MethodPost, c.base+"/updates/", list, nil)
+}
+
+// Get returning metric by type and name with JSON API
+func (c *Client) Get(ctx context.Context, mType, name string) (Metric, error) {
+	var result Metric
+
+	err := c.doJSON(ctx, http.MethodPost, c.base+"/value/", Metric{ID: name, MType: mType}, &result)
+
+	return result, err
+}
+
+// List returning all metrics sorted by type and name
+func (c *Client) List(ctx context.Context) ([]Metric, error) {
+	var result []Metric
+
+	err := c.doJSON(ctx, http.MethodGet, c.base+"/values/", nil, &result)
+
+	return result, err
+}
+
+// Delete removing metric. It requires admin token if authentication is enabled on server
+func (c *Client) Delete(ctx context.Context, mType, name string) error {
+	_, err := c.do(ctx, http.MethodDelete, c.path("value", mType, name), nil)
+
+	return err
+}
+
+// Ping checking that server is available
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodGet, c.base+"/ping", nil)
+
+	return err
+}
+
+// path returning url of path-based API with escaped segments
+func (c *Client) path(segments ...string) string {
+	var b strings.Builder
+
+	b.WriteString(c.base)
+
+	for _, s := range segments {
+		b.WriteByte('/')
+		b.WriteString(url.PathEscape(s))
+	}
+
+	return b.String()
+}
+
+// doJSON sending in as JSON body if it is not nil and decoding response to out if it is not nil
+func (c *Client) doJSON(ctx context.Context, method, url string, in, out interface{}) error {
+	var body []byte
+
+	if in != nil {
+		var err error
+
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+
+	b, err := c.do(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err = json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("unable to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// do sending request and returning body of successful response.
+// Responses with status 4xx and 5xx are returned as *StatusError
+func (c *Client) do(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > 0 {
+		req.Header.Set(contentTypeHeader, contentTypeJSON)
+	} else {
+		req.Header.Set(contentTypeHeader, contentTypeText)
+	}
+
+	for _, h := range c.hooks {
+		if err = h.Request(req, body); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSuffix(string(b), "\n"),
+		}
+	}
+
+	for _, h := range c.hooks {
+		if err = h.Response(resp, b); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mtrrun/internal/auth"
+	"github.com/mtrrun/internal/envelope"
+	"github.com/mtrrun/internal/handler"
+	"github.com/mtrrun/internal/middleware"
+	"github.com/mtrrun/internal/repository"
+	"github.com/mtrrun/internal/service"
+)
+
+const (
+	testKey        = "secret"
+	testWriteToken = "write-token"
+	testAdminToken = "admin-token"
+)
+
+// newTestServer running real routes of server with signing and authentication
+func newTestServer(t *testing.T) *httptest.Server {
+	tokens, err := auth.NewStore([]auth.Token{
+		{Name: "writer", Hash: auth.HashToken(testWriteToken), Scopes: []auth.Scope{auth.ScopeRead, auth.ScopeWrite}},
+		{Name: "admin", Hash: auth.HashToken(testAdminToken), Scopes: []auth.Scope{auth.ScopeAdmin}},
+	})
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	r.Use(middleware.Hash(&middleware.HashConfig{Key: testKey}))
+
+	handler.New(&handler.Config{
+		Router: r,
+		MetSrv: service.NewMetricService(&service.MetricServiceConfig{
+			MetRepo: repository.NewMetricMemCache(),
+		}),
+		ReadMiddlewares:  []mux.MiddlewareFunc{middleware.Auth(&middleware.AuthConfig{Store: tokens, Scope: auth.ScopeRead})},
+		WriteMiddlewares: []mux.MiddlewareFunc{middleware.Auth(&middleware.AuthConfig{Store: tokens, Scope: auth.ScopeWrite})},
+		AdminMiddlewares: []mux.MiddlewareFunc{middleware.Auth(&middleware.AuthConfig{Store: tokens, Scope: auth.ScopeAdmin})},
+	})
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newTestClient(srv *httptest.Server, token string) *Client {
+	return New(&Config{
+		Address: srv.URL,
+		Hooks:   []Hook{BearerToken(token), HMAC(testKey)},
+	})
+}
+
+func TestClientPathAPI(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(newTestServer(t), testWriteToken)
+
+	require.NoError(t, c.Ping(ctx))
+
+	require.NoError(t, c.UpdateGauge(ctx, "Load1", 0.25))
+	require.NoError(t, c.UpdateCounter(ctx, "Requests", 2))
+	require.NoError(t, c.UpdateCounter(ctx, "Requests", 3))
+
+	g, err := c.GetGauge(ctx, "Load1")
+	require.NoError(t, err)
+	require.Equal(t, 0.25, g)
+
+	v, err := c.GetCounter(ctx, "Requests")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), v)
+}
+
+func TestClientJSONAPI(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(newTestServer(t), testWriteToken)
+
+	m, err := c.Update(ctx, Counter("Requests", 2))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), *m.Delta)
+
+	require.NoError(t, c.Updates(ctx, []Metric{Counter("Requests", 3), Gauge("Alloc", 1.5)}))
+
+	m, err = c.Get(ctx, TypeCounter, "Requests")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), *m.Delta)
+
+	list, err := c.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Metric{Counter("Requests", 5), Gauge("Alloc", 1.5)}, list)
+}
+
+func TestMetricJSON(t *testing.T) {
+	b, err := json.Marshal([]Metric{Counter("Requests", 2), Gauge("Alloc", 1.5)})
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"id":"Requests","type":"counter","delta":2},{"id":"Alloc","type":"gauge","value":1.5}]`, string(b))
+
+	// Fields which client doesn't know, e.g. labels of agent in pull mode, are ignored
+	var m Metric
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"Alloc","type":"gauge","value":1.5,"labels":{"host":"a"}}`), &m))
+	require.Equal(t, Gauge("Alloc", 1.5), m)
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t)
+	c := newTestClient(srv, testWriteToken)
+
+	_, err := c.GetGauge(ctx, "Missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = c.Update(ctx, Metric{ID: "Requests", MType: TypeCounter})
+	require.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = c.Get(ctx, "histogram", "Requests")
+	require.ErrorIs(t, err, ErrNotImplemented)
+
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, 501, statusErr.StatusCode)
+
+	// Deleting requires admin scope
+	require.NoError(t, c.UpdateCounter(ctx, "Requests", 1))
+	require.ErrorIs(t, c.Delete(ctx, TypeCounter, "Requests"), ErrForbidden)
+
+	_, err = New(&Config{Address: srv.URL}).List(ctx)
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	// Request signed with other key is rejected by server
+	_, err = New(&Config{Address: srv.URL, Hooks: []Hook{BearerToken(testWriteToken), HMAC("other")}}).List(ctx)
+	require.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestClientDelete(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t)
+	writer := newTestClient(srv, testWriteToken)
+	admin := newTestClient(srv, testAdminToken)
+
+	require.NoError(t, writer.UpdateGauge(ctx, "Alloc", 1))
+	require.NoError(t, admin.Delete(ctx, TypeGauge, "Alloc"))
+	require.ErrorIs(t, admin.Delete(ctx, TypeGauge, "Alloc"), ErrNotFound)
+
+	_, err := writer.GetGauge(ctx, "Alloc")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClientEncrypt(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	decrypt := &middleware.DecryptConfig{Decrypter: envelope.NewDecrypter(key)}
+
+	r := mux.NewRouter()
+	r.Use(middleware.Decrypt(decrypt))
+	r.Use(middleware.Hash(&middleware.HashConfig{Key: testKey}))
+
+	handler.New(&handler.Config{
+		Router: r,
+		MetSrv: service.NewMetricService(&service.MetricServiceConfig{
+			MetRepo: repository.NewMetricMemCache(),
+		}),
+		WriteMiddlewares: []mux.MiddlewareFunc{middleware.RequireEncryption(decrypt)},
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(&Config{
+		Address: srv.URL,
+		Hooks:   []Hook{HMAC(testKey), Encrypt(&key.PublicKey)},
+	})
+
+	require.NoError(t, c.UpdateGauge(ctx, "Load", 0.5))
+	require.NoError(t, c.Updates(ctx, []Metric{Counter("Requests", 2)}))
+
+	m, err := c.Get(ctx, TypeCounter, "Requests")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), *m.Delta)
+
+	// Reading doesn't require encryption, writing does
+	plain := New(&Config{Address: srv.URL, Hooks: []Hook{HMAC(testKey)}})
+
+	m, err = plain.Get(ctx, TypeGauge, "Load")
+	require.NoError(t, err)
+	require.Equal(t, 0.5, *m.Value)
+
+	_, err = plain.Update(ctx, Gauge("Load", 1))
+	require.ErrorIs(t, err, ErrBadRequest)
+}
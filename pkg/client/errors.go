@@ -0,0 +1,50 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors for responses of server. They are matched with errors.Is
+var (
+	ErrBadRequest     = errors.New("bad request")
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrForbidden      = errors.New("forbidden")
+	ErrNotFound       = errors.New("metric not found")
+	ErrNotImplemented = errors.New("metric type is not implemented")
+
+	// ErrInvalidSignature is returned by HMAC if response is not signed with the same key
+	ErrInvalidSignature = errors.New("invalid signature of response")
+)
+
+// StatusError is error response of server
+type StatusError struct {
+	StatusCode int
+
+	// Body of response without trailing new line
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Is matching status of response with ErrBadRequest, ErrUnauthorized,
+// ErrForbidden, ErrNotFound and ErrNotImplemented
+func (e *StatusError) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusNotImplemented:
+		return target == ErrNotImplemented
+	default:
+		return false
+	}
+}
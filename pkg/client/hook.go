@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"io"
+	"net/http"
+
+	"github.com/mtrrun/internal/envelope"
+	"github.com/mtrrun/internal/sign"
+)
+
+const authorizationHeader = "Authorization"
+
+// Hook changing requests before they are sent and checking responses,
+// e.g. for authentication and signing
+type Hook interface {
+	// Request is called before request is sent. body is request body, it could be empty
+	Request(r *http.Request, body []byte) error
+
+	// Response is called for successful responses. body is response body.
+	// Error responses are not checked, they are returned as *StatusError
+	Response(r *http.Response, body []byte) error
+}
+
+// HookFunc is Hook which changes requests only
+type HookFunc func(r *http.Request, body []byte) error
+
+// Request calling f
+func (f HookFunc) Request(r *http.Request, body []byte) error {
+	return f(r, body)
+}
+
+// Response doing nothing
+func (f HookFunc) Response(*http.Response, []byte) error {
+	return nil
+}
+
+// BearerToken returning hook which sets token for server API
+func BearerToken(token string) Hook {
+	return HookFunc(func(r *http.Request, _ []byte) error {
+		r.Header.Set(authorizationHeader, "Bearer "+token)
+
+		return nil
+	})
+}
+
+// HMAC returning hook which signs requests and verifies responses with HMAC-SHA256 like agent.
+// Requests without body are signed by path with query
+func HMAC(key string) Hook {
+	return &hmacHook{
+		key: []byte(key),
+	}
+}
+
+type hmacHook struct {
+	key []byte
+}
+
+func (h *hmacHook) Request(r *http.Request, body []byte) error {
+	data := body
+	if len(data) == 0 {
+		data = []byte(r.URL.RequestURI())
+	}
+
+	r.Header.Set(sign.Header, sign.Sum(h.key, data))
+
+	return nil
+}
+
+func (h *hmacHook) Response(r *http.Response, body []byte) error {
+	if !sign.Verify(h.key, body, r.Header.Get(sign.Header)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Encrypt returning hook which encrypts request bodies with public key of server like agent.
+// Requests with method POST are encrypted even without body, because server with
+// private keys accepts only encrypted writes. Hook must be after HMAC, signature is
+// calculated by plaintext body
+func Encrypt(pub *rsa.PublicKey) Hook {
+	e := envelope.NewEncrypter(pub)
+
+	return HookFunc(func(r *http.Request, body []byte) error {
+		if len(body) == 0 && r.Method != http.MethodPost {
+			return nil
+		}
+
+		data, err := e.Encrypt(body)
+		if err != nil {
+			return err
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		r.ContentLength = int64(len(data))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+
+		r.Header.Set(envelope.Header, envelope.Scheme)
+		r.Header.Set(envelope.KeyIDHeader, e.KeyID())
+
+		return nil
+	})
+}
+
+// EncryptFile returning Encrypt hook with public key of server from PEM file
+func EncryptFile(path string) (Hook, error) {
+	pub, err := envelope.ReadPublicKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Encrypt(pub), nil
+}
@@ -0,0 +1,461 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/mtrrun/internal/agent"
+	"github.com/mtrrun/internal/relabel"
+)
+
+// Agent collecting metrics and sending them to server
+type Agent struct {
+	a *agent.Agent
+}
+
+// Client sending requests to server, see WithClient
+type Client interface {
+	DoRequest(ctx context.Context, method string, url string, header map[string]string, body []byte) error
+	Shutdown()
+}
+
+// Formats of dry run
+const (
+	DryRunText = agent.DryRunText
+	DryRunJSON = agent.DryRunJSON
+)
+
+// Config full configuration of Agent, see WithConfig
+type Config struct {
+	// Servers which receive reports. Options below are used for every destination
+	// which doesn't set its own value, except credentials, see Destination.InheritCredentials.
+	// If Destinations is empty that will be use one destination with Host and options below
+	Destinations []Destination
+
+	// Failover sends report to the first destination and metrics which were not delivered
+	// to the next one in order of Destinations. Else report is sent to every destination
+	Failover bool
+
+	// Client for sending reports. If Client is empty that will be use http client
+	// configured with options below
+	Client Client
+
+	ReportInterval time.Duration
+	PollInterval   time.Duration
+
+	// Address of server. If Host is empty that will be use default value - "127.0.0.1:8080"
+	Host string
+
+	// Count of workers which send requests.
+	// If MaxRequestsPerMoment is empty that will be use default value - 5.
+	MaxRequestsPerMoment int
+
+	// Size of queue with requests for workers. Report waits while queue is full.
+	// If QueueSize is empty that will be use default value - 100.
+	QueueSize int
+
+	// Limit of requests per second for all workers and max count of requests
+	// which could be sent at once after idle. If RequestsPerSecond is empty rate is not limited.
+	// If Burst is empty that will be use default value - 1.
+	RequestsPerSecond float64
+	Burst             int
+
+	Timeout      time.Duration
+	MaxIdleConns int
+
+	// DisableCompression disables gzip for request and response bodies
+	DisableCompression bool
+
+	// Key for HMAC-SHA256 signing. If Key is empty signing is disabled
+	Key string
+
+	// Path to PEM file with public key of server for encryption of
+	// request bodies. If CryptoKey is empty encryption is disabled
+	CryptoKey string
+
+	// TLS options. If any of them is set agent uses https
+	TLSCA         string // Path to PEM file with CA certificates for verifying server
+	TLSCert       string // Path to PEM file with client certificate for mutual TLS
+	TLSKey        string // Path to PEM file with client key for mutual TLS
+	TLSServerName string // Server name in certificate if it differs from host
+
+	// Bearer token for server API. If TokenFile is set token is read
+	// from file and re-read when file changes
+	Token     string
+	TokenFile string
+
+	// Count of retries for failed requests.
+	// If Retries is empty that will be use default value - 3. Negative value disables retries
+	Retries              int
+	RetryInitialInterval time.Duration
+	RetryMaxInterval     time.Duration
+
+	// Every ResyncInterval all metrics are reported, between that only changed metrics are reported.
+	// If ResyncInterval is empty that will be use default value - 5 minutes. Negative value disables change tracking.
+	ResyncInterval time.Duration
+
+	// Names of metrics after relabeling which are reported every time even if they are not changed
+	AlwaysReport []string
+
+	// Names or patterns of gauges which are reported with min, max, avg and count of samples
+	// since last report as gauges <name>_min, <name>_max, <name>_avg, <name>_samples.
+	// If Aggregate is empty aggregation is disabled
+	Aggregate []string
+
+	// Rules for names of metrics and static labels. If Relabel is nil names are not changed
+	Relabel *RelabelConfig
+
+	// Max duration of final report on shutdown.
+	// If ShutdownTimeout is empty that will be use default value - 5 seconds.
+	ShutdownTimeout time.Duration
+
+	// Directory for reports which were not delivered while server is unavailable.
+	// If SpoolDir is empty reports are not stored on disk
+	SpoolDir     string
+	SpoolMaxSize int64
+	SpoolMaxAge  time.Duration
+
+	// Address for pull mode, metrics are served on /metrics and /metrics/json.
+	// If ListenAddress is empty pull mode is disabled
+	ListenAddress string
+
+	// DisablePush disables reports to server, metrics are available in pull mode only
+	DisablePush bool
+
+	// DryRun prints requests to DryRunOutput instead of sending them.
+	// If DryRunFormat is empty that will be use default value - DryRunText.
+	// If DryRunOutput is empty that will be use os.Stdout
+	DryRun       bool
+	DryRunFormat string
+	DryRunOutput io.Writer
+}
+
+// Destination server which receives reports with its own options, see WithDestinations.
+// Empty options are taken from Config
+type Destination struct {
+	// Name of destination in logs. If Name is empty that will be use Host
+	Name string
+
+	Host string
+
+	// Client for sending reports. If Client is empty that will be use http client
+	// configured with options below
+	Client Client
+
+	MaxRequestsPerMoment int
+	QueueSize            int
+	RequestsPerSecond    float64
+	Burst                int
+
+	Timeout            time.Duration
+	MaxIdleConns       int
+	DisableCompression bool
+
+	// Credentials and TLS options are not taken from Config unless InheritCredentials is set,
+	// so token or key of one server is not sent to other servers
+	InheritCredentials bool
+
+	Key       string
+	CryptoKey string
+
+	TLSCA         string
+	TLSCert       string
+	TLSKey        string
+	TLSServerName string
+
+	Token     string
+	TokenFile string
+
+	Retries              int
+	RetryInitialInterval time.Duration
+	RetryMaxInterval     time.Duration
+
+	SpoolDir     string
+	SpoolMaxSize int64
+	SpoolMaxAge  time.Duration
+}
+
+// Actions of relabel rules
+const (
+	// RelabelKeep drops metrics which names don't match regex
+	RelabelKeep = relabel.ActionKeep
+	// RelabelDrop drops metrics which names match regex
+	RelabelDrop = relabel.ActionDrop
+	// RelabelReplace renames metrics which names match regex to replacement.
+	// Replacement could contain capture groups, e.g. "${1}"
+	RelabelReplace = relabel.ActionReplace
+)
+
+// RelabelRule rule for names of metrics. Regex must match whole name
+type RelabelRule struct {
+	Action      string
+	Regex       string
+	Replacement string
+}
+
+// RelabelConfig rules for names of reported metrics
+type RelabelConfig struct {
+	// Rules are applied in order, metric which is dropped by rule is not checked by next rules.
+	// If metrics of the same type get the same name, agent keeps metric with the least original name
+	Rules []RelabelRule
+
+	// Prefix is added to names after rules
+	Prefix string
+
+	// Labels attached to every metric. Server keys metrics only by name, so in push mode
+	// labels are added to names like "Alloc;env=prod;host=a"
+	Labels map[string]string
+}
+
+// Option configuring Agent in New
+type Option func(o *options)
+
+type options struct {
+	config   Config
+	registry *Registry
+}
+
+// WithConfig setting configuration of agent. Fields of c which are not empty
+// replace values which were set by previous options, other fields are kept
+func WithConfig(c Config) Option {
+	return func(o *options) {
+		src := reflect.ValueOf(c)
+		dst := reflect.ValueOf(&o.config).Elem()
+
+		for i := 0; i < src.NumField(); i++ {
+			if !src.Field(i).IsZero() {
+				dst.Field(i).Set(src.Field(i))
+			}
+		}
+	}
+}
+
+// WithHost setting address of server, e.g. "127.0.0.1:8080"
+func WithHost(host string) Option {
+	return func(o *options) {
+		o.config.Host = host
+	}
+}
+
+// WithReportInterval setting interval of reports to server
+func WithReportInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.config.ReportInterval = d
+	}
+}
+
+// WithPollInterval setting default interval of collectors
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.config.PollInterval = d
+	}
+}
+
+// WithClient setting client for requests to server instead of default http client.
+// Options of default client in Config are ignored
+func WithClient(c Client) Option {
+	return func(o *options) {
+		o.config.Client = c
+	}
+}
+
+// WithDestinations setting servers which receive reports instead of host.
+// Options of Config are used for every destination which doesn't set its own value
+func WithDestinations(d ...Destination) Option {
+	return func(o *options) {
+		o.config.Destinations = append(o.config.Destinations, d...)
+	}
+}
+
+// WithFailover sending reports to destinations in priority order instead of all of them.
+// Metrics which were not delivered to destination are sent to the next one
+func WithFailover() Option {
+	return func(o *options) {
+		o.config.Failover = true
+	}
+}
+
+// WithAggregate setting names or patterns of gauges which are reported with min, max, avg
+// and count of samples between reports, see Config.Aggregate
+func WithAggregate(patterns ...string) Option {
+	return func(o *options) {
+		o.config.Aggregate = append(o.config.Aggregate, patterns...)
+	}
+}
+
+// WithRegistry setting registry which is reported instead of DefaultRegistry
+func WithRegistry(r *Registry) Option {
+	return func(o *options) {
+		o.registry = r
+	}
+}
+
+// New constructor for Agent. Without options agent reports DefaultRegistry
+// to server on 127.0.0.1:8080
+func New(opts ...Option) (*Agent, error) {
+	o := &options{
+		registry: DefaultRegistry,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.config.Host == "" {
+		o.config.Host = defaultHost
+	}
+
+	a, err := agent.New(o.config.agentConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	a.CustomTracker(o.registry.t)
+
+	return &Agent{a: a}, nil
+}
+
+// Register adding collector which is run with Run. Names of collectors and their metrics must be unique.
+// If c is nil that will be use default configuration
+func (a *Agent) Register(col Collector, c *CollectorConfig) error {
+	cc := &agent.CollectorConfig{}
+
+	if c != nil {
+		cc.Interval = c.Interval
+		cc.Timeout = c.Timeout
+	}
+
+	return a.a.Register(agentCollector(col), cc)
+}
+
+// Run collecting and reporting metrics until ctx is cancelled or Shutdown is called.
+// Metrics which were collected after last report are sent with final report,
+// then all resources of agent are closed, so Run could be called only once
+func (a *Agent) Run(ctx context.Context) error {
+	return a.a.Run(ctx)
+}
+
+// Once running every collector one time and sending one report, then all resources
+// of agent are closed. Returns error if report was not delivered
+func (a *Agent) Once(ctx context.Context) error {
+	return a.a.Once(ctx)
+}
+
+// Shutdown stopping Run and waiting for final report. It could be called before Run
+func (a *Agent) Shutdown() {
+	a.a.Shutdown()
+}
+
+// agentConfig mapping configuration to configuration of agent
+func (c *Config) agentConfig() *agent.Config {
+	ac := &agent.Config{
+		Failover:             c.Failover,
+		ReportInterval:       c.ReportInterval,
+		PollInterval:         c.PollInterval,
+		Host:                 c.Host,
+		MaxRequestsPerMoment: c.MaxRequestsPerMoment,
+		QueueSize:            c.QueueSize,
+		RequestsPerSecond:    c.RequestsPerSecond,
+		Burst:                c.Burst,
+		Timeout:              c.Timeout,
+		MaxIdleConns:         c.MaxIdleConns,
+		DisableCompression:   c.DisableCompression,
+		Key:                  c.Key,
+		CryptoKey:            c.CryptoKey,
+		TLSCA:                c.TLSCA,
+		TLSCert:              c.TLSCert,
+		TLSKey:               c.TLSKey,
+		TLSServerName:        c.TLSServerName,
+		Token:                c.Token,
+		TokenFile:            c.TokenFile,
+		Retries:              c.Retries,
+		RetryInitialInterval: c.RetryInitialInterval,
+		RetryMaxInterval:     c.RetryMaxInterval,
+		ResyncInterval:       c.ResyncInterval,
+		AlwaysReport:         c.AlwaysReport,
+		Aggregate:            c.Aggregate,
+		ShutdownTimeout:      c.ShutdownTimeout,
+		SpoolDir:             c.SpoolDir,
+		SpoolMaxSize:         c.SpoolMaxSize,
+		SpoolMaxAge:          c.SpoolMaxAge,
+		ListenAddress:        c.ListenAddress,
+		DisablePush:          c.DisablePush,
+		DryRun:               c.DryRun,
+		DryRunFormat:         c.DryRunFormat,
+		DryRunOutput:         c.DryRunOutput,
+	}
+
+	if c.Client != nil {
+		ac.Client = c.Client
+	}
+
+	if c.Relabel != nil {
+		ac.Relabel = c.Relabel.relabelConfig()
+	}
+
+	for _, d := range c.Destinations {
+		ac.Destinations = append(ac.Destinations, d.agentDestination())
+	}
+
+	return ac
+}
+
+// agentDestination mapping destination to destination of agent
+func (d *Destination) agentDestination() agent.Destination {
+	ad := agent.Destination{
+		Name:                 d.Name,
+		Host:                 d.Host,
+		MaxRequestsPerMoment: d.MaxRequestsPerMoment,
+		QueueSize:            d.QueueSize,
+		RequestsPerSecond:    d.RequestsPerSecond,
+		Burst:                d.Burst,
+		Timeout:              d.Timeout,
+		MaxIdleConns:         d.MaxIdleConns,
+		DisableCompression:   d.DisableCompression,
+		InheritCredentials:   d.InheritCredentials,
+		Key:                  d.Key,
+		CryptoKey:            d.CryptoKey,
+		TLSCA:                d.TLSCA,
+		TLSCert:              d.TLSCert,
+		TLSKey:               d.TLSKey,
+		TLSServerName:        d.TLSServerName,
+		Token:                d.Token,
+		TokenFile:            d.TokenFile,
+		Retries:              d.Retries,
+		RetryInitialInterval: d.RetryInitialInterval,
+		RetryMaxInterval:     d.RetryMaxInterval,
+		SpoolDir:             d.SpoolDir,
+		SpoolMaxSize:         d.SpoolMaxSize,
+		SpoolMaxAge:          d.SpoolMaxAge,
+	}
+
+	// Nil interface must stay nil, else agent doesn't create default client
+	if d.Client != nil {
+		ad.Client = d.Client
+	}
+
+	return ad
+}
+
+// relabelConfig mapping relabel configuration to configuration of relabeler
+func (c *RelabelConfig) relabelConfig() *relabel.Config {
+	rc := &relabel.Config{
+		Rules:  make([]relabel.Rule, 0, len(c.Rules)),
+		Prefix: c.Prefix,
+		Labels: c.Labels,
+	}
+
+	for _, r := range c.Rules {
+		rc.Rules = append(rc.Rules, relabel.Rule{
+			Action:      r.Action,
+			Regex:       r.Regex,
+			Replacement: r.Replacement,
+		})
+	}
+
+	return rc
+}
+
+const defaultHost = "127.0.0.1:8080"
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/mtrrun/internal/agent"
+	"github.com/mtrrun/internal/agent/collector"
+	"github.com/mtrrun/internal/host"
+)
+
+// Collector is source of metrics which is run by agent on its own interval.
+// Collector creates its metrics and registers them in Collect
+type Collector interface {
+	// Name of collector for configuration and self-metrics
+	Name() string
+
+	// Describe returning descriptions of metrics which are known before collecting
+	Describe() []Description
+
+	// Collect updating values of metrics and registering them in r.
+	// ctx is cancelled when timeout of collector is expired or agent is stopped
+	Collect(ctx context.Context, r *Registry) error
+}
+
+// CollectorConfig configuration of collector in agent
+type CollectorConfig struct {
+	// If Interval is empty that will be use poll interval of agent
+	Interval time.Duration
+
+	// Max duration of one collect. If Timeout is empty that will be use Interval
+	Timeout time.Duration
+}
+
+// RuntimeConfig configuration of runtime collector
+type RuntimeConfig struct {
+	// Compat enables legacy metrics from runtime.MemStats with their old names
+	Compat bool
+}
+
+// HostConfig configuration of host collector
+type HostConfig struct {
+	// Root of proc file system. If ProcRoot is empty that will be use default value - "/proc".
+	ProcRoot string
+
+	// Root of sys file system. If SysRoot is empty that will be use default value - "/sys".
+	SysRoot string
+
+	// Mount points for usage of file systems. Mount points which could not be read are skipped.
+	// If Filesystems is empty usage is not read
+	Filesystems []string
+}
+
+// Names of built-in collectors
+const (
+	RuntimeCollectorName = collector.RuntimeName
+	HostCollectorName    = collector.HostName
+)
+
+// NewRuntimeCollector constructor for collector of Go runtime metrics
+func NewRuntimeCollector(c *RuntimeConfig) Collector {
+	rc := &collector.RuntimeConfig{}
+
+	if c != nil {
+		rc.Compat = c.Compat
+	}
+
+	return builtinCollector{c: collector.NewRuntime(rc)}
+}
+
+// NewHostCollector constructor for collector of Linux host metrics
+func NewHostCollector(c *HostConfig) Collector {
+	hc := &host.Config{}
+
+	if c != nil {
+		hc.ProcRoot = c.ProcRoot
+		hc.SysRoot = c.SysRoot
+		hc.Filesystems = c.Filesystems
+	}
+
+	return builtinCollector{c: collector.NewHost(hc)}
+}
+
+// builtinCollector mapping collector of agent to Collector
+type builtinCollector struct {
+	c agent.Collector
+}
+
+func (b builtinCollector) Name() string {
+	return b.c.Name()
+}
+
+func (b builtinCollector) Describe() []Description {
+	return newDescriptions(b.c.Describe())
+}
+
+func (b builtinCollector) Collect(ctx context.Context, r *Registry) error {
+	return b.c.Collect(ctx, r.t)
+}
+
+// customCollector mapping Collector to collector of agent
+type customCollector struct {
+	c Collector
+}
+
+func (c customCollector) Name() string {
+	return c.c.Name()
+}
+
+func (c customCollector) Describe() []agent.Description {
+	d := c.c.Describe()
+	result := make([]agent.Description, 0, len(d))
+
+	for _, v := range d {
+		result = append(result, agent.Description{Name: v.Name, Help: v.Help})
+	}
+
+	return result
+}
+
+func (c customCollector) Collect(ctx context.Context, t agent.Tracker) error {
+	return c.c.Collect(ctx, &Registry{t: t})
+}
+
+// agentCollector returning collector of agent for col
+func agentCollector(col Collector) agent.Collector {
+	if b, ok := col.(builtinCollector); ok {
+		return b.c
+	}
+
+	return customCollector{c: col}
+}
+
+func newDescriptions(d []agent.Description) []Description {
+	result := make([]Description, 0, len(d))
+
+	for _, v := range d {
+		result = append(result, Description{Name: v.Name, Help: v.Help})
+	}
+
+	return result
+}
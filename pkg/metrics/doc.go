@@ -0,0 +1,32 @@
+// Package metrics is public library for pushing metrics of Go services to metrics server.
+//
+// Metrics are created with package-level constructors and tracked in DefaultRegistry:
+//
+//	orders := metrics.NewCounter("Orders", "count of created orders")
+//	orders.Inc()
+//
+// Agent sends tracked metrics to server every report interval:
+//
+//	a, err := metrics.New(metrics.WithHost("127.0.0.1:8080"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	err = a.Run(ctx)
+//
+// # Stability
+//
+// Package follows semantic versioning of module. Until next major version:
+//   - exported functions, types, constants and variables are not removed or renamed
+//     and their signatures are not changed;
+//   - methods are not added to interfaces which are implemented outside of package,
+//     i.e. Collector and Client. Metric and its kinds are implemented only by package,
+//     so their methods could be added;
+//   - fields of Config and new options could be added, zero values of new fields
+//     keep previous behavior;
+//   - names of metrics of built-in collectors are not changed.
+//
+// Types of package don't expose types of internal/, so internal changes don't break API.
+// Log messages and text of errors are not part of API.
+// Identifiers marked as "Experimental" could be changed in any release.
+package metrics
@@ -0,0 +1,70 @@
+package metrics_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mtrrun/pkg/metrics"
+)
+
+func ExampleNewCounter() {
+	orders := metrics.NewCounter("Orders", "count of created orders")
+	defer metrics.Unregister(orders)
+
+	orders.Inc()
+	orders.Add(2)
+
+	fmt.Println(orders.Value())
+	// Output: 3
+}
+
+func ExampleNewGaugeFunc() {
+	queue := []string{"a", "b"}
+
+	length := metrics.NewGaugeFunc("QueueLength", "length of queue", func() float64 {
+		return float64(len(queue))
+	})
+	defer metrics.Unregister(length)
+
+	fmt.Println(length.Value())
+	// Output: 2
+}
+
+// printClient printing requests instead of sending them
+type printClient struct{}
+
+func (printClient) DoRequest(_ context.Context, method, url string, _ map[string]string, body []byte) error {
+	fmt.Println(method, url, string(body))
+
+	return nil
+}
+
+func (printClient) Shutdown() {}
+
+func ExampleNew() {
+	registry := metrics.NewRegistry()
+
+	orders := metrics.With(registry).NewCounter("Orders", "count of created orders")
+	orders.Add(3)
+
+	a, err := metrics.New(
+		metrics.WithHost("metrics.local:8080"),
+		metrics.WithReportInterval(time.Minute),
+		metrics.WithClient(printClient{}),
+		metrics.WithRegistry(registry),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Cancelled context stops agent, metrics are sent with final report
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err = a.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+	// Output: POST http://metrics.local:8080/update/ {"id":"Orders","type":"counter","delta":3}
+}
@@ -0,0 +1,272 @@
+package metrics
+
+import (
+	"github.com/mtrrun/internal/agent"
+)
+
+// Description of metric
+type Description struct {
+	Name string
+	Help string
+}
+
+// Value of metric
+type Value struct {
+	v agent.Value
+}
+
+// Float64 returning value as float64
+func (v Value) Float64() float64 {
+	return v.v.Float64()
+}
+
+// Int64 returning value as int64. Float value is truncated
+func (v Value) Int64() int64 {
+	return v.v.Int64()
+}
+
+// String formatting value like it is sent to server
+func (v Value) String() string {
+	return v.v.String()
+}
+
+// Metric is interface for all metric types. Metrics are created only by constructors of package
+type Metric interface {
+	Desc() Description
+	Value() Value
+
+	// unwrap returning metric of agent
+	unwrap() agent.Metric
+}
+
+// Counter is metric which value only increases. Agent sends difference since previous report
+type Counter interface {
+	Metric
+	Inc()
+	Add(int64)
+}
+
+// Gauge is metric which value could go up and down
+type Gauge interface {
+	Metric
+	Set(float64)
+	Inc()
+	Dec()
+	Add(float64)
+	Sub(float64)
+}
+
+// Histogram is distribution of values, it is reported as quantiles and count
+type Histogram interface {
+	Metric
+	Set(counts []uint64, buckets []float64)
+	Quantile(q float64) float64
+	Count() uint64
+}
+
+// GaugeFunc is gauge which value is returned by function on every report
+type GaugeFunc interface {
+	Metric
+}
+
+// CounterFunc is counter which cumulative value is returned by function on every report.
+// Value which is less than previous is treated as reset of source
+type CounterFunc interface {
+	Metric
+}
+
+// Registry is container with tracked metrics
+type Registry struct {
+	t agent.Tracker
+}
+
+// DefaultRegistry is registry for metrics which are created with package-level constructors.
+// Agent reports it if other registry is not set with WithRegistry
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry constructor for Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		t: agent.NewTracker(),
+	}
+}
+
+// Register adding metric to registry. Metric with the same name is replaced
+func (r *Registry) Register(m Metric) {
+	r.t.Track(m.unwrap())
+}
+
+// Unregister removing metric from registry
+func (r *Registry) Unregister(m Metric) {
+	r.t.Untrack(m.unwrap())
+}
+
+// Register adding metric to DefaultRegistry. Metric with the same name is replaced
+func Register(m Metric) {
+	DefaultRegistry.Register(m)
+}
+
+// Unregister removing metric from DefaultRegistry
+func Unregister(m Metric) {
+	DefaultRegistry.Unregister(m)
+}
+
+// Factory creating metrics and registering them in its registry
+type Factory struct {
+	registry *Registry
+}
+
+// With returning Factory for registry r
+func With(r *Registry) Factory {
+	return Factory{
+		registry: r,
+	}
+}
+
+// NewCounter creating counter and registering it in DefaultRegistry
+func NewCounter(name string, help string) Counter {
+	return With(DefaultRegistry).NewCounter(name, help)
+}
+
+// NewGauge creating gauge and registering it in DefaultRegistry
+func NewGauge(name string, help string) Gauge {
+	return With(DefaultRegistry).NewGauge(name, help)
+}
+
+// NewHistogram creating histogram and registering it in DefaultRegistry
+func NewHistogram(name string, help string) Histogram {
+	return With(DefaultRegistry).NewHistogram(name, help)
+}
+
+// NewGaugeFunc creating gauge with value of f and registering it in DefaultRegistry.
+// f must be safe for concurrent use
+func NewGaugeFunc(name string, help string, f func() float64) GaugeFunc {
+	return With(DefaultRegistry).NewGaugeFunc(name, help, f)
+}
+
+// NewCounterFunc creating counter with cumulative value of f and registering it in DefaultRegistry.
+// f must be safe for concurrent use
+func NewCounterFunc(name string, help string, f func() int64) CounterFunc {
+	return With(DefaultRegistry).NewCounterFunc(name, help, f)
+}
+
+// NewCounter creating counter and registering it
+func (f Factory) NewCounter(name string, help string) Counter {
+	c := counter{c: agent.NewCounter(name, help)}
+	c.m = c.c
+	f.registry.Register(c)
+
+	return c
+}
+
+// NewGauge creating gauge and registering it
+func (f Factory) NewGauge(name string, help string) Gauge {
+	g := gauge{g: agent.NewGauge(name, help)}
+	g.m = g.g
+	f.registry.Register(g)
+
+	return g
+}
+
+// NewHistogram creating histogram and registering it
+func (f Factory) NewHistogram(name string, help string) Histogram {
+	h := histogram{h: agent.NewHistogram(name, help)}
+	h.m = h.h
+	f.registry.Register(h)
+
+	return h
+}
+
+// NewGaugeFunc creating gauge with value of fn and registering it. fn must be safe for concurrent use
+func (f Factory) NewGaugeFunc(name string, help string, fn func() float64) GaugeFunc {
+	g := base{m: agent.NewGaugeFunc(name, help, fn)}
+	f.registry.Register(g)
+
+	return g
+}
+
+// NewCounterFunc creating counter with cumulative value of fn and registering it.
+// fn must be safe for concurrent use
+func (f Factory) NewCounterFunc(name string, help string, fn func() int64) CounterFunc {
+	c := base{m: agent.NewCounterFunc(name, help, fn)}
+	f.registry.Register(c)
+
+	return c
+}
+
+// base mapping metric of agent to Metric
+type base struct {
+	m agent.Metric
+}
+
+func (b base) Desc() Description {
+	d := b.m.Desc()
+
+	return Description{
+		Name: d.Name,
+		Help: d.Help,
+	}
+}
+
+func (b base) Value() Value {
+	return Value{v: b.m.Value()}
+}
+
+func (b base) unwrap() agent.Metric {
+	return b.m
+}
+
+type counter struct {
+	base
+	c agent.Counter
+}
+
+func (c counter) Inc() {
+	c.c.Inc()
+}
+
+func (c counter) Add(v int64) {
+	c.c.Add(v)
+}
+
+type gauge struct {
+	base
+	g agent.Gauge
+}
+
+func (g gauge) Set(v float64) {
+	g.g.Set(v)
+}
+
+func (g gauge) Inc() {
+	g.g.Inc()
+}
+
+func (g gauge) Dec() {
+	g.g.Dec()
+}
+
+func (g gauge) Add(v float64) {
+	g.g.Add(v)
+}
+
+func (g gauge) Sub(v float64) {
+	g.g.Sub(v)
+}
+
+type histogram struct {
+	base
+	h agent.Histogram
+}
+
+func (h histogram) Set(counts []uint64, buckets []float64) {
+	h.h.Set(counts, buckets)
+}
+
+func (h histogram) Quantile(q float64) float64 {
+	return h.h.Quantile(q)
+}
+
+func (h histogram) Count() uint64 {
+	return h.h.Count()
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordClient remembering bodies of requests
+type recordClient struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (c *recordClient) DoRequest(_ context.Context, _, _ string, _ map[string]string, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.bodies = append(c.bodies, string(body))
+
+	return nil
+}
+
+func (c *recordClient) Shutdown() {}
+
+func TestNewReportsDefaultRegistry(t *testing.T) {
+	c := NewCounter("Requests", "")
+	defer Unregister(c)
+
+	c.Add(2)
+
+	client := &recordClient{}
+
+	a, err := New(WithClient(client))
+	require.NoError(t, err)
+	require.NoError(t, a.Once(context.Background()))
+	require.Equal(t, []string{`{"id":"Requests","type":"counter","delta":2}`}, client.bodies)
+
+	// Other registry is not affected by package-level constructors
+	client = &recordClient{}
+
+	a, err = New(WithClient(client), WithRegistry(NewRegistry()))
+	require.NoError(t, err)
+	require.NoError(t, a.Once(context.Background()))
+	require.Empty(t, client.bodies)
+}
+
+func TestWithConfig(t *testing.T) {
+	o := &options{}
+
+	for _, opt := range []Option{
+		WithHost("metrics.local:8080"),
+		WithAggregate("Load"),
+		WithConfig(Config{
+			ReportInterval: time.Minute,
+			Aggregate:      []string{"Alloc"},
+		}),
+	} {
+		opt(o)
+	}
+
+	// Empty fields of Config don't discard previous options
+	require.Equal(t, "metrics.local:8080", o.config.Host)
+	require.Equal(t, time.Minute, o.config.ReportInterval)
+	require.Equal(t, []string{"Alloc"}, o.config.Aggregate)
+}
+
+// testCollector collector with one gauge
+type testCollector struct {
+	g Gauge
+}
+
+func (c *testCollector) Name() string {
+	return "test"
+}
+
+func (c *testCollector) Describe() []Description {
+	return []Description{c.g.Desc()}
+}
+
+func (c *testCollector) Collect(_ context.Context, r *Registry) error {
+	c.g.Set(1.5)
+	r.Register(c.g)
+
+	return nil
+}
+
+func TestAgentRegister(t *testing.T) {
+	registry := NewRegistry()
+	client := &recordClient{}
+
+	a, err := New(WithClient(client), WithRegistry(registry), WithPollInterval(time.Minute))
+	require.NoError(t, err)
+
+	// Metric is not registered, collector registers it
+	g := With(NewRegistry()).NewGauge("Load", "")
+
+	require.NoError(t, a.Register(&testCollector{g: g}, nil))
+	require.Error(t, a.Register(&testCollector{g: g}, nil))
+	require.NoError(t, a.Register(NewRuntimeCollector(nil), &CollectorConfig{Interval: time.Minute}))
+
+	require.NoError(t, a.Once(context.Background()))
+	require.Contains(t, client.bodies, `{"id":"Load","type":"gauge","value":1.5}`)
+}